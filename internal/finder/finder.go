@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"go/ast"
 	"go/build"
+	"go/constant"
 	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/livebud/marshaler/internal/imports"
+
 	"golang.org/x/mod/modfile"
 )
 
@@ -23,7 +27,220 @@ type Finder struct {
 	dir string
 }
 
-func (f *Finder) Find(importPath string, name string) (ast.Expr, error) {
+// Find the type spec for name within importPath. Doc comments are kept so the
+// generators can read directives.
+func (f *Finder) Find(importPath string, name string) (*ast.TypeSpec, error) {
+	files, err := f.parsePackage(importPath)
+	if err != nil {
+		return nil, err
+	}
+	// Look for the type spec
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range gen.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if ts.Name.Name == name {
+							// Ungrouped declarations attach the doc comment to the
+							// GenDecl rather than the TypeSpec
+							if ts.Doc == nil && !gen.Lparen.IsValid() {
+								ts.Doc = gen.Doc
+							}
+							return ts, nil
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("finder:could not find type definition for %q.%s", importPath, name)
+}
+
+// Constants returns the names of the constants declared with the type name
+// within importPath, in the order they're declared
+func (f *Finder) Constants(importPath string, name string) (names []string, err error) {
+	files, err := f.parsePackage(importPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			// Constants without a type or value repeat the previous ones (e.g. iota)
+			var typ ast.Expr
+			for _, spec := range gen.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				if vs.Type != nil || len(vs.Values) > 0 {
+					typ = vs.Type
+				}
+				if ident, ok := typ.(*ast.Ident); !ok || ident.Name != name {
+					continue
+				}
+				for _, ident := range vs.Names {
+					if ident.Name != "_" {
+						names = append(names, ident.Name)
+					}
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// Constant evaluates the constant name declared within importPath
+func (f *Finder) Constant(importPath string, name string) (constant.Value, error) {
+	files, err := f.parsePackage(importPath)
+	if err != nil {
+		return nil, err
+	}
+	e := &evaluator{f, importPath, map[string]constExpr{}, map[string]bool{}}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			// Constants without values repeat the previous ones (e.g. iota)
+			var values []ast.Expr
+			for i, spec := range gen.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				if len(vs.Values) > 0 {
+					values = vs.Values
+				}
+				for j, ident := range vs.Names {
+					if j < len(values) {
+						e.consts[ident.Name] = constExpr{values[j], i}
+					}
+				}
+			}
+		}
+	}
+	if _, ok := e.consts[name]; !ok {
+		return nil, fmt.Errorf("finder:could not find constant %q.%s", importPath, name)
+	}
+	return e.eval(ast.NewIdent(name), 0)
+}
+
+// constExpr is the expression of a constant and the iota it was declared with
+type constExpr struct {
+	expr ast.Expr
+	iota int
+}
+
+// evaluator evaluates constant expressions within a package
+type evaluator struct {
+	finder     *Finder
+	importPath string
+	consts     map[string]constExpr
+	evaluating map[string]bool
+}
+
+func (e *evaluator) eval(x ast.Expr, iota int) (constant.Value, error) {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(x.Value, x.Kind, 0), nil
+	case *ast.ParenExpr:
+		return e.eval(x.X, iota)
+	case *ast.Ident:
+		switch x.Name {
+		case "iota":
+			return constant.MakeInt64(int64(iota)), nil
+		case "true", "false":
+			return constant.MakeBool(x.Name == "true"), nil
+		}
+		c, ok := e.consts[x.Name]
+		if !ok {
+			return nil, fmt.Errorf("finder:unknown constant %q", x.Name)
+		} else if e.evaluating[x.Name] {
+			return nil, fmt.Errorf("finder:constant %q refers to itself", x.Name)
+		}
+		e.evaluating[x.Name] = true
+		defer delete(e.evaluating, x.Name)
+		return e.eval(c.expr, c.iota)
+	case *ast.UnaryExpr:
+		v, err := e.eval(x.X, iota)
+		if err != nil {
+			return nil, err
+		}
+		return constant.UnaryOp(x.Op, v, 0), nil
+	case *ast.BinaryExpr:
+		l, err := e.eval(x.X, iota)
+		if err != nil {
+			return nil, err
+		}
+		r, err := e.eval(x.Y, iota)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Op {
+		case token.SHL, token.SHR:
+			shift, ok := constant.Uint64Val(r)
+			if !ok {
+				return nil, fmt.Errorf("finder:invalid shift %s", r)
+			}
+			return constant.Shift(l, x.Op, uint(shift)), nil
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(l, x.Op, r)), nil
+		case token.QUO:
+			if l.Kind() == constant.Int && r.Kind() == constant.Int {
+				// Integer division truncates
+				return constant.BinaryOp(l, token.QUO_ASSIGN, r), nil
+			}
+		}
+		return constant.BinaryOp(l, x.Op, r), nil
+	case *ast.SelectorExpr:
+		// Constants from other packages (e.g. size.Small)
+		pkg, ok := x.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		importPath, err := e.finder.Resolve(e.importPath, pkg.Name)
+		if err != nil {
+			return nil, err
+		}
+		return e.finder.Constant(importPath, x.Sel.Name)
+	case *ast.CallExpr:
+		// Conversions (e.g. Size(16)) keep the value
+		if _, ok := x.Fun.(*ast.Ident); ok && len(x.Args) == 1 {
+			return e.eval(x.Args[0], iota)
+		}
+	}
+	return nil, fmt.Errorf("finder:unable to evaluate constant expression %T", x)
+}
+
+// Resolve the import path of a package name that's used within importPath
+func (f *Finder) Resolve(importPath string, name string) (string, error) {
+	files, err := f.parsePackage(importPath)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return "", err
+			}
+			if spec.Name != nil && spec.Name.Name == name {
+				return path, nil
+			} else if spec.Name == nil && imports.AssumedName(path) == name {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("finder:could not find import for %q in %q", name, importPath)
+}
+
+// parsePackage parses each valid Go file in importPath
+func (f *Finder) parsePackage(importPath string) (files []*ast.File, err error) {
 	gomod, err := os.ReadFile(filepath.Join(f.dir, "go.mod"))
 	if err != nil {
 		return nil, err
@@ -39,45 +256,34 @@ func (f *Finder) Find(importPath string, name string) (ast.Expr, error) {
 		importPackage = f.importRemote
 	}
 	// Import the package
-	pkg, err := importPackage(modFile, importPath, name)
+	pkg, err := importPackage(modFile, importPath)
 	if err != nil {
 		return nil, err
 	}
 	// Parse each valid Go file
 	fset := token.NewFileSet()
 	for _, filename := range pkg.GoFiles {
-		filename = filepath.Join(f.dir, filename)
+		filename = filepath.Join(pkg.Dir, filename)
 		code, err := os.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
-		file, err := parser.ParseFile(fset, filename, code, parser.DeclarationErrors)
+		file, err := parser.ParseFile(fset, filename, code, parser.DeclarationErrors|parser.ParseComments)
 		if err != nil {
 			return nil, err
 		}
-		// Look for the type spec
-		for _, decl := range file.Decls {
-			if gen, ok := decl.(*ast.GenDecl); ok {
-				for _, spec := range gen.Specs {
-					if ts, ok := spec.(*ast.TypeSpec); ok {
-						if ts.Name.Name == name {
-							return ts.Type, nil
-						}
-					}
-				}
-			}
-		}
+		files = append(files, file)
 	}
-	return nil, fmt.Errorf("finder:could not find type definition for %q.%s", importPath, name)
+	return files, nil
 }
 
-func (f *Finder) importLocal(modFile *modfile.File, importPath string, name string) (*build.Package, error) {
+func (f *Finder) importLocal(modFile *modfile.File, importPath string) (*build.Package, error) {
 	dir := filepath.Join(f.dir, trimModulePath(modFile.Module.Mod.Path, importPath))
 	return build.Import(".", dir, build.ImportMode(0))
 }
 
-func (f *Finder) importRemote(modFile *modfile.File, importPath string, name string) (*build.Package, error) {
-	return nil, fmt.Errorf("find remote for %q.%s not implemneted yet", importPath, name)
+func (f *Finder) importRemote(modFile *modfile.File, importPath string) (*build.Package, error) {
+	return nil, fmt.Errorf("find remote for %q not implemneted yet", importPath)
 }
 
 func trimModulePath(modulePath string, importPath string) string {
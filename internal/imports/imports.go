@@ -17,7 +17,7 @@ type Import struct {
 type Imports []*Import
 
 func (i *Imports) Import(path string) (name string, err error) {
-	name = assumedName(path)
+	name = AssumedName(path)
 	for _, imp := range *i {
 		if imp.Name == name {
 			if imp.Path != path {
@@ -34,9 +34,9 @@ func (i *Imports) Import(path string) (name string, err error) {
 	return name, nil
 }
 
-// assumedName returns the assumed name for the import path. It's pulled from:
+// AssumedName returns the assumed name for the import path. It's pulled from:
 // https://cs.opensource.google/go/x/tools/+/refs/tags/v0.6.0:internal/imports/fix.go;l=1144
-func assumedName(importPath string) string {
+func AssumedName(importPath string) string {
 	base := path.Base(importPath)
 	if strings.HasPrefix(base, "v") {
 		if _, err := strconv.Atoi(base[1:]); err == nil {
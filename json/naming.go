@@ -0,0 +1,76 @@
+package json
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// rename the Go field name using the naming strategy
+func rename(naming, name string) (string, error) {
+	words := splitWords(name)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	switch naming {
+	case "":
+		return name, nil
+	case "snake":
+		return strings.Join(words, "_"), nil
+	case "kebab":
+		return strings.Join(words, "-"), nil
+	case "camel":
+		for i := 1; i < len(words); i++ {
+			words[i] = title(words[i])
+		}
+		return strings.Join(words, ""), nil
+	case "pascal":
+		// Keep initialisms whole (e.g. URLPath => URLPath)
+		words = splitWords(name)
+		for i := range words {
+			words[i] = title(words[i])
+		}
+		return strings.Join(words, ""), nil
+	default:
+		return "", fmt.Errorf("json: unknown naming %q, expected snake, camel, kebab or pascal", naming)
+	}
+}
+
+// splitWords splits the Go name into words, keeping initialisms together
+// (e.g. UserID => User ID, URLPath => URL Path)
+func splitWords(name string) (words []string) {
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, curr := runes[i-1], runes[i]
+		switch {
+		case curr == '_':
+			// Underscores separate words, but aren't part of them
+			if start < i {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case unicode.IsUpper(curr) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			// userID => user ID
+			words = append(words, string(runes[start:i]))
+			start = i
+		case unicode.IsUpper(prev) && unicode.IsUpper(curr) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// URLPath => URL Path
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+func title(word string) string {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return word
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
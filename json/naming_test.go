@@ -0,0 +1,45 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// Ensures that field names are split into words around initialisms.
+func TestSplitWords(t *testing.T) {
+	is := is.New(t)
+	is.Equal(splitWords("UserID"), []string{"User", "ID"})
+	is.Equal(splitWords("URLPath"), []string{"URL", "Path"})
+	is.Equal(splitWords("HTTPServerURL"), []string{"HTTP", "Server", "URL"})
+	is.Equal(splitWords("Base64Data"), []string{"Base64", "Data"})
+	is.Equal(splitWords("ID"), []string{"ID"})
+	is.Equal(splitWords("already_snake"), []string{"already", "snake"})
+}
+
+// Ensures that field names can be renamed with each strategy.
+func TestRename(t *testing.T) {
+	is := is.New(t)
+	tests := []struct {
+		naming, name, expect string
+	}{
+		{"", "UserID", "UserID"},
+		{"snake", "UserID", "user_id"},
+		{"snake", "URLPath", "url_path"},
+		{"kebab", "HTTPServerURL", "http-server-url"},
+		{"camel", "UserID", "userId"},
+		{"camel", "URLPath", "urlPath"},
+		{"pascal", "URLPath", "URLPath"},
+		{"pascal", "ID", "ID"},
+		{"pascal", "userID", "UserID"},
+		{"pascal", "already_snake", "AlreadySnake"},
+		{"pascal", "name", "Name"},
+	}
+	for _, test := range tests {
+		actual, err := rename(test.naming, test.name)
+		is.NoErr(err)
+		is.Equal(actual, test.expect)
+	}
+	_, err := rename("screaming", "UserID")
+	is.True(err != nil)
+}
@@ -0,0 +1,281 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/livebud/marshaler/json/scanner"
+)
+
+var (
+	// ErrNotFound is returned when a path doesn't exist in the value
+	ErrNotFound = errors.New("path not found")
+	// ErrTestFailed is returned when a test operation doesn't match
+	ErrTestFailed = errors.New("test failed")
+)
+
+// Func applies a single operation at the path. The operations are "add",
+// "remove", "replace" and "test", which decode the JSON value, and "get" and
+// "put", which copy and move use to take the Go value at one path and add it
+// at another without going through JSON.
+type Func func(op string, path []string, value *Value) error
+
+// Value is the value of an operation
+type Value struct {
+	// JSON is the value of add, replace and test operations
+	JSON []byte
+	// Go is a deep copy of the value at the from path of copy and move
+	// operations, stored by get and added by put
+	Go interface{}
+}
+
+// Operation is a single JSON patch operation
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value []byte
+}
+
+// Error is returned when an operation can't be applied
+type Error struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("patch: operation %d (%s %s): %s", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Apply the JSON patch (RFC 6902) operations in order. Operations are applied
+// in place, so the value may be partially patched when an error is returned.
+func Apply(patch []byte, fn Func) error {
+	ops, err := Parse(patch)
+	if err != nil {
+		return err
+	}
+	for i, op := range ops {
+		if err := apply(op, fn); err != nil {
+			return &Error{i, op.Op, op.Path, err}
+		}
+	}
+	return nil
+}
+
+func apply(op Operation, fn Func) error {
+	path, err := Split(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("missing value")
+		}
+		return fn(op.Op, path, &Value{JSON: op.Value})
+	case "remove":
+		return fn(op.Op, path, nil)
+	case "copy", "move":
+		from, err := Split(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" && isPrefix(from, path) && len(from) < len(path) {
+			return fmt.Errorf("can't move %s into itself", op.From)
+		}
+		value := new(Value)
+		if err := fn("get", from, value); err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			if err := fn("remove", from, nil); err != nil {
+				return err
+			}
+		}
+		return fn("put", path, value)
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// Clone deep copies a value decoded into an interface{}, which holds maps,
+// slices and scalars
+func Clone(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = Clone(value)
+		}
+		return m
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		arr := make([]interface{}, len(v))
+		for i, value := range v {
+			arr[i] = Clone(value)
+		}
+		return arr
+	default:
+		return v
+	}
+}
+
+// Equal compares values decoded into an interface{}
+func Equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !Equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case string, float64, bool, nil:
+		return a == b
+	default:
+		return false
+	}
+}
+
+// isPrefix returns true if prefix is a prefix of path
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse the list of operations
+func Parse(patch []byte) (ops []Operation, err error) {
+	s := scanner.NewBytesScanner(patch)
+	if _, err := s.Expect(scanner.TLBRACKET); err != nil {
+		return nil, err
+	}
+	for index := 0; ; index++ {
+		tok, buf, err := s.Scan()
+		if err != nil {
+			return nil, err
+		} else if tok == scanner.TRBRACKET {
+			return ops, nil
+		} else if index > 0 {
+			if tok != scanner.TCOMMA {
+				return nil, fmt.Errorf(`%s: expected "]" or ",", got %q`, s.Position(), scanner.TokenName(tok))
+			}
+		} else {
+			s.Unscan(tok, buf)
+		}
+		op, err := parseOperation(s)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+}
+
+func parseOperation(s scanner.Scanner) (op Operation, err error) {
+	if _, err := s.Expect(scanner.TLBRACE); err != nil {
+		return op, err
+	}
+	for index := 0; ; index++ {
+		tok, buf, err := s.Scan()
+		if err != nil {
+			return op, err
+		} else if tok == scanner.TRBRACE {
+			return op, nil
+		} else if index > 0 {
+			if tok != scanner.TCOMMA {
+				return op, fmt.Errorf(`%s: expected "}" or ",", got %q`, s.Position(), scanner.TokenName(tok))
+			}
+			if tok, buf, err = s.Scan(); err != nil {
+				return op, err
+			}
+		}
+		if tok != scanner.TSTRING {
+			return op, fmt.Errorf(`%s: expected string, got %q`, s.Position(), scanner.TokenName(tok))
+		}
+		key := string(buf)
+		if _, err := s.Expect(scanner.TCOLON); err != nil {
+			return op, err
+		}
+		switch key {
+		case "op":
+			err = s.ReadString(&op.Op)
+		case "path":
+			err = s.ReadString(&op.Path)
+		case "from":
+			err = s.ReadString(&op.From)
+		case "value":
+			op.Value, err = s.RawValue()
+		default:
+			err = s.Skip()
+		}
+		if err != nil {
+			return op, err
+		}
+	}
+}
+
+// Split the JSON pointer (RFC 6901) into its unescaped segments
+func Split(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	segments := strings.Split(pointer[1:], "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+	return segments, nil
+}
+
+// Index parses an array index segment. The "-" segment refers to the end of
+// the array.
+func Index(segment string, length int) (int, error) {
+	if segment == "-" {
+		return length, nil
+	}
+	if segment == "" || (len(segment) > 1 && segment[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid index %q", ErrNotFound, segment)
+	}
+	i, err := strconv.Atoi(segment)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%w: invalid index %q", ErrNotFound, segment)
+	}
+	return i, nil
+}
@@ -0,0 +1,97 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// Ensures that JSON pointers are split and unescaped.
+func TestSplit(t *testing.T) {
+	is := is.New(t)
+	path, err := Split("")
+	is.NoErr(err)
+	is.Equal(len(path), 0)
+	path, err = Split("/a~1b/~01/")
+	is.NoErr(err)
+	is.Equal(path, []string{"a/b", "~1", ""})
+	_, err = Split("a")
+	is.True(err != nil)
+}
+
+// Ensures that array indexes are parsed.
+func TestIndex(t *testing.T) {
+	is := is.New(t)
+	i, err := Index("12", 3)
+	is.NoErr(err)
+	is.Equal(i, 12)
+	i, err = Index("-", 3)
+	is.NoErr(err)
+	is.Equal(i, 3)
+	_, err = Index("01", 3)
+	is.True(errors.Is(err, ErrNotFound))
+	_, err = Index("a", 3)
+	is.True(errors.Is(err, ErrNotFound))
+}
+
+// Ensures that copy and move are applied with get, remove and put.
+func TestApply(t *testing.T) {
+	is := is.New(t)
+	var calls []string
+	err := Apply([]byte(`[
+		{"op":"add","path":"/a","value":{"b":[1, 2]}},
+		{"value":true,"path":"/c","op":"test"},
+		{"op":"copy","from":"/a","path":"/d"},
+		{"op":"move","from":"/a","path":"/e"}
+	]`), func(op string, path []string, value *Value) error {
+		call := op + " /" + strings.Join(path, "/")
+		if op == "get" {
+			value.Go = "got"
+		} else if op == "put" {
+			call += " " + value.Go.(string)
+		} else if value != nil {
+			call += " " + string(value.JSON)
+		}
+		calls = append(calls, call)
+		return nil
+	})
+	is.NoErr(err)
+	is.Equal(calls, []string{
+		`add /a {"b":[1,2]}`,
+		`test /c true`,
+		`get /a`,
+		`put /d got`,
+		`get /a`,
+		`remove /a`,
+		`put /e got`,
+	})
+}
+
+// Ensures that errors include the operation and path.
+func TestApplyError(t *testing.T) {
+	is := is.New(t)
+	err := Apply([]byte(`[{"op":"remove","path":"/a"},{"op":"move","from":"/a","path":"/a/b"}]`), func(op string, path []string, value *Value) error {
+		return nil
+	})
+	is.Equal(err.Error(), "patch: operation 1 (move /a/b): can't move /a into itself")
+	err = Apply([]byte(`[{"op":"replace","path":"/a"}]`), func(op string, path []string, value *Value) error {
+		return nil
+	})
+	is.Equal(err.Error(), "patch: operation 0 (replace /a): missing value")
+}
+
+// Ensures that values decoded into an interface{} are deep copied.
+func TestClone(t *testing.T) {
+	is := is.New(t)
+	v := map[string]interface{}{"a": []interface{}{1.0, map[string]interface{}{"b": "c"}}}
+	c := Clone(v).(map[string]interface{})
+	is.True(Equal(v, c))
+	c["a"].([]interface{})[1].(map[string]interface{})["b"] = "d"
+	is.Equal(v["a"].([]interface{})[1].(map[string]interface{})["b"], "c")
+	is.True(!Equal(v, c))
+	is.True(Equal(nil, nil))
+	is.True(!Equal(1.0, "1"))
+	is.True(!Equal([]interface{}{}, []interface{}{nil}))
+}
@@ -0,0 +1,54 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/livebud/marshaler/json/patch"
+)
+
+// Paths is a tree of selected keys. A nil Paths selects every key below it.
+type Paths map[string]Paths
+
+// Parse the JSON pointers (RFC 6901) into a tree of selected keys. Pointers
+// match struct fields and map keys. Arrays are transparent, so "/items/name"
+// selects the name of every item.
+func Parse(pointers ...string) (Paths, error) {
+	root := Paths{}
+	for _, pointer := range pointers {
+		if pointer == "" {
+			// The empty pointer selects the whole document
+			return nil, nil
+		}
+		segments, err := patch.Split(pointer)
+		if err != nil {
+			return nil, fmt.Errorf("project: %w", err)
+		}
+		node := root
+		for i, key := range segments {
+			child, ok := node[key]
+			if ok && child == nil {
+				// Already selected by a shorter pointer
+				break
+			}
+			if i == len(segments)-1 {
+				node[key] = nil
+				break
+			}
+			if !ok {
+				child = Paths{}
+				node[key] = child
+			}
+			node = child
+		}
+	}
+	return root, nil
+}
+
+// Select returns the paths below key and whether key is selected at all
+func (p Paths) Select(key string) (Paths, bool) {
+	if p == nil {
+		return nil, true
+	}
+	child, ok := p[key]
+	return child, ok
+}
@@ -0,0 +1,41 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// Ensures that pointers are parsed into a tree of keys.
+func TestParse(t *testing.T) {
+	is := is.New(t)
+	paths, err := Parse("/a/b", "/a/c~1d", "/e", "/e/f")
+	is.NoErr(err)
+	is.Equal(paths, Paths{
+		"a": Paths{"b": nil, "c/d": nil},
+		"e": nil,
+	})
+	_, err = Parse("a")
+	is.True(err != nil)
+}
+
+// Ensures that selecting walks the tree.
+func TestSelect(t *testing.T) {
+	is := is.New(t)
+	paths, err := Parse("/a/b")
+	is.NoErr(err)
+	a, ok := paths.Select("a")
+	is.True(ok)
+	_, ok = a.Select("c")
+	is.True(!ok)
+	b, ok := a.Select("b")
+	is.True(ok)
+	// Everything below b is selected
+	_, ok = b.Select("anything")
+	is.True(ok)
+	// The empty pointer selects everything
+	all, err := Parse("")
+	is.NoErr(err)
+	_, ok = all.Select("a")
+	is.True(ok)
+}
@@ -1,9 +1,15 @@
 package scanner
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"strconv"
+	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
@@ -12,66 +18,239 @@ const (
 	bufSize = 4096
 )
 
-// Scanner is a tokenizer for JSON input from an io.Reader.
+var hex = "0123456789abcdef"
+
+// Scanner is a tokenizer for JSON input from an io.Reader or a byte slice.
 type Scanner interface {
 	Pos() int
+	Position() Position
 	Scan() (int, []byte, error)
 	Expect(token int) ([]byte, error)
 	Unscan(tok int, b []byte)
 	ReadString(target *string) error
 	ReadInt(target *int) error
+	ReadInt8(target *int8) error
+	ReadInt16(target *int16) error
+	ReadInt32(target *int32) error
 	ReadInt64(target *int64) error
 	ReadUint(target *uint) error
+	ReadUint8(target *uint8) error
+	ReadUint16(target *uint16) error
+	ReadUint32(target *uint32) error
 	ReadUint64(target *uint64) error
 	ReadFloat32(target *float32) error
 	ReadFloat64(target *float64) error
 	ReadBool(target *bool) error
+	ReadBase64(target *[]byte, enc *base64.Encoding) error
 	ReadMap(target *map[string]interface{}) error
 	ReadArray(target *[]interface{}) error
+	ReadInterface(target *interface{}) error
+	RawValue() ([]byte, error)
+	Skip() error
+	Lookahead() (Scanner, error)
 }
 
 type scanner struct {
 	r       io.Reader
 	c       rune
 	scratch [bufSize]byte
-	buf     [bufSize]byte
+	buf     []byte
 	buflen  int
 	idx     int
-	pos     int
+	cur     mark // after the current rune
+	prev    mark // before the current rune
+	start   mark // before the last token
+	size    int  // size of the current rune
 	tmpc    rune
-	tmp     struct {
-		tok int
-		b   []byte
-		err error
+	// Tokens put back with Unscan, read again in reverse order
+	unscanned []unscanned
+	// The last token returned by Scan
+	last int
+	// Tokens read while looking ahead
+	recording bool
+	recorded  []unscanned
+	lenient   bool
+	exact     bool
+	strict    bool
+	relaxed   bool
+	unquoted  bool
+	single    bool
+}
+
+// unscanned is a token that was put back along with where it was read
+type unscanned struct {
+	tok   int
+	b     []byte
+	start mark
+	end   mark
+}
+
+// Position is a location within the input
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in runes, starting at 1
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// mark is a position along with the number of runes before it
+type mark struct {
+	Position
+	runes int
+}
+
+// begin is the mark at the start of the input
+var begin = mark{Position: Position{Line: 1, Column: 1}}
+
+// Option configures the scanner
+type Option func(s *scanner)
+
+// Lenient coerces tokens of the wrong type instead of returning a TypeError.
+// Strings are parsed into numbers and booleans (e.g. "42" and "true"), numbers
+// and booleans are read into strings, and booleans are read as 1 or 0.
+func Lenient() Option {
+	return func(s *scanner) {
+		s.lenient = true
+	}
+}
+
+// ExactIntegers accepts integers written as exact numbers with exponents or
+// fractions (e.g. 1e3 or 2.0)
+func ExactIntegers() Option {
+	return func(s *scanner) {
+		s.exact = true
+	}
+}
+
+// Strict rejects input that isn't valid JSON according to RFC 8259, such as
+// unknown characters between tokens, leading zeros, unescaped control
+// characters, invalid UTF-8, lone surrogates and missing separators.
+func Strict() Option {
+	return func(s *scanner) {
+		s.strict = true
+	}
+}
+
+// Relaxed accepts // and /* */ comments and trailing commas in objects and
+// arrays, like JSONC.
+func Relaxed() Option {
+	return func(s *scanner) {
+		s.relaxed = true
+	}
+}
+
+// UnquotedKeys accepts object keys that are identifiers without quotes (e.g.
+// {name: "a"}), like JSON5.
+func UnquotedKeys() Option {
+	return func(s *scanner) {
+		s.unquoted = true
+	}
+}
+
+// SingleQuotes accepts strings quoted with single quotes (e.g. 'a'), like
+// JSON5.
+func SingleQuotes() Option {
+	return func(s *scanner) {
+		s.single = true
 	}
 }
 
 // NewScanner initializes a new scanner with a given reader.
-func NewScanner(r io.Reader) Scanner {
-	s := &scanner{r: r, buflen: -1}
+func NewScanner(r io.Reader, options ...Option) Scanner {
+	s := &scanner{r: r, buf: make([]byte, bufSize), buflen: -1, cur: begin, start: begin}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// NewBytesScanner initializes a new scanner that tokenizes buf in place.
+// Strings without escapes are returned as subslices of buf, so buf must not
+// be modified while scanning.
+func NewBytesScanner(buf []byte, options ...Option) Scanner {
+	s := &scanner{buf: buf, buflen: len(buf), cur: begin, start: begin}
+	for _, option := range options {
+		option(s)
+	}
 	return s
 }
 
-// Pos returns the current rune position of the scanner.
+// TypeError is returned when a token doesn't match the type being read
+type TypeError struct {
+	Token    int
+	Value    string
+	Expected string
+	Position Position
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("unexpected %s at %s: %s; expected %s", TokenName(e.Token), e.Position, e.Value, e.Expected)
+}
+
+var (
+	// ErrOverflow is returned when a number doesn't fit the target
+	ErrOverflow = errors.New("overflows")
+	// ErrFraction is returned when an integer target gets a fraction
+	ErrFraction = errors.New("has a fraction")
+	// ErrNotInteger is returned for exact integers that aren't written as
+	// integers (e.g. 1e3) unless ExactIntegers is set
+	ErrNotInteger = errors.New("isn't written as an integer")
+)
+
+// NumberError is returned when a number can't be read into the target
+type NumberError struct {
+	Value    string
+	Type     string
+	Position Position
+	Err      error
+}
+
+func (e *NumberError) Error() string {
+	return fmt.Sprintf("invalid %s at %s: %s %s", e.Type, e.Position, e.Value, e.Err)
+}
+
+func (e *NumberError) Unwrap() error {
+	return e.Err
+}
+
+// typeError returns a TypeError for the token
+func (s *scanner) typeError(tok int, b []byte, expected string) error {
+	return &TypeError{tok, string(b), expected, s.start.Position}
+}
+
+// Pos returns the number of runes the scanner has read.
 func (s *scanner) Pos() int {
-	return s.pos
+	return s.cur.runes
+}
+
+// Position returns the position of the last token the scanner has read.
+func (s *scanner) Position() Position {
+	return s.start.Position
 }
 
 // read retrieves the next rune from the reader.
 func (s *scanner) read() error {
+	s.prev = s.cur
 	if s.tmpc > 0 {
 		s.c = s.tmpc
 		s.tmpc = 0
+		s.advance(s.size)
 		return nil
 	}
 
 	// Read from the reader if the buffer is empty.
-	if s.idx >= s.buflen {
-		var err error
-		if s.buflen, err = s.r.Read(s.buf[0:]); err != nil {
+	for s.idx >= s.buflen {
+		if s.r == nil {
+			return io.EOF
+		}
+		n, err := s.r.Read(s.buf[0:])
+		s.buflen, s.idx = n, 0
+		if n == 0 && err != nil {
 			return err
 		}
-		s.idx = 0
 	}
 
 	// Read a single byte and then determine if utf8 decoding is needed.
@@ -79,29 +258,51 @@ func (s *scanner) read() error {
 	if b < utf8.RuneSelf {
 		s.c = rune(b)
 		s.idx++
+		s.advance(1)
 	} else {
-		// Read a new buffer if we don't have at least the max size of a UTF8 character.
-		if s.idx+utf8.UTFMax >= s.buflen {
-			s.buf[0] = b
-			var err error
-			if s.buflen, err = s.r.Read(s.buf[1:]); err != nil {
-				return err
+		// Move the partial character to the front and read the rest of it.
+		if s.r != nil && !utf8.FullRune(s.buf[s.idx:s.buflen]) {
+			s.buflen = copy(s.buf[0:], s.buf[s.idx:s.buflen])
+			s.idx = 0
+			for !utf8.FullRune(s.buf[0:s.buflen]) {
+				n, err := s.r.Read(s.buf[s.buflen:])
+				s.buflen += n
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
 			}
-			s.buflen += 1
 		}
 
 		var size int
-		s.c, size = utf8.DecodeRune(s.buf[s.idx:])
+		s.c, size = utf8.DecodeRune(s.buf[s.idx:s.buflen])
 		s.idx += size
+		if s.strict && s.c == utf8.RuneError && size == 1 {
+			return fmt.Errorf("invalid UTF-8 at %s", s.cur.Position)
+		}
+		s.advance(size)
 	}
-
-	s.pos++
 	return nil
 }
 
+// advance moves the position past the current rune
+func (s *scanner) advance(size int) {
+	s.size = size
+	s.cur.runes++
+	s.cur.Offset += size
+	if s.c == '\n' {
+		s.cur.Line++
+		s.cur.Column = 1
+	} else {
+		s.cur.Column++
+	}
+}
+
 // unread places the current rune back on the reader.
 func (s *scanner) unread() {
 	s.tmpc = s.c
+	s.cur = s.prev
 }
 
 // expect reads the next rune and checks that it matches.
@@ -109,23 +310,60 @@ func (s *scanner) expect(c rune) error {
 	if err := s.read(); err != nil {
 		return err
 	} else if s.c != c {
-		return fmt.Errorf("unexpected char: %q", s.c)
+		return fmt.Errorf("unexpected char at %s: %q; expected %q", s.start.Position, s.c, c)
 	}
 	return nil
 }
 
 // Scan returns the next JSON token from the reader.
 func (s *scanner) Scan() (int, []byte, error) {
-	if s.tmp.tok != 0 {
-		tok, b := s.tmp.tok, s.tmp.b
-		s.tmp.tok, s.tmp.b = 0, nil
-		return tok, b, nil
+	if n := len(s.unscanned); n > 0 {
+		u := s.unscanned[n-1]
+		s.unscanned = s.unscanned[:n-1]
+		s.start, s.cur, s.last = u.start, u.end, u.tok
+		s.record(u.tok, u.b)
+		return u.tok, u.b, nil
+	}
+	tok, b, err := s.scanToken()
+	if err != nil {
+		return 0, nil, err
+	}
+	// Drop trailing commas before the end of an object or array
+	if tok == TCOMMA && s.relaxed && s.last != 0 && s.last != TLBRACE && s.last != TLBRACKET && s.last != TCOMMA {
+		start := s.start
+		next, nb, err := s.scanToken()
+		if err == nil && (next == TRBRACE || next == TRBRACKET) {
+			tok, b = next, nb
+		} else if err == nil {
+			s.unscan(next, nb)
+			s.start = start
+		} else if err != io.EOF {
+			return 0, nil, err
+		}
+	}
+	s.last = tok
+	s.record(tok, b)
+	return tok, b, nil
+}
+
+// record keeps a copy of the token while looking ahead
+func (s *scanner) record(tok int, b []byte) {
+	if s.recording {
+		s.recorded = append(s.recorded, unscanned{tok, append([]byte(nil), b...), s.start, s.cur})
 	}
+}
 
+// scanToken reads the next token from the reader.
+func (s *scanner) scanToken() (int, []byte, error) {
 	for {
 		if err := s.read(); err != nil {
 			return 0, nil, err
 		}
+		s.start = s.prev
+
+		if s.unquoted && (s.c == '_' || s.c == '$' || unicode.IsLetter(s.c)) {
+			return s.scanIdent()
+		}
 
 		switch s.c {
 		case '{':
@@ -141,7 +379,18 @@ func (s *scanner) Scan() (int, []byte, error) {
 		case ',':
 			return TCOMMA, []byte{','}, nil
 		case '"':
-			return s.scanString()
+			return s.scanString('"')
+		case '\'':
+			if s.single {
+				return s.scanString('\'')
+			}
+		case '/':
+			if s.relaxed {
+				if err := s.scanComment(); err != nil {
+					return 0, nil, err
+				}
+				continue
+			}
 		case 't':
 			return s.scanTrue()
 		case 'f':
@@ -153,6 +402,11 @@ func (s *scanner) Scan() (int, []byte, error) {
 		if (s.c >= '0' && s.c <= '9') || s.c == '-' {
 			return s.scanNumber()
 		}
+
+		// Only whitespace is allowed between tokens
+		if s.strict && s.c != ' ' && s.c != '\t' && s.c != '\n' && s.c != '\r' {
+			return 0, nil, fmt.Errorf("unexpected char at %s: %q", s.start.Position, s.c)
+		}
 	}
 }
 
@@ -163,21 +417,148 @@ func (s *scanner) Expect(token int) ([]byte, error) {
 		return nil, err
 	}
 	if tok != token {
-		return nil, fmt.Errorf("expected token %d, got %d", token, tok)
+		return nil, fmt.Errorf("unexpected %s at %s: %s; expected %s", TokenName(tok), s.start.Position, string(buf), TokenName(token))
 	}
 	return buf, nil
 }
 
 // Unscan adds a token and byte array back onto the buffer to be read
-// on the next call to Scan().
+// on the next call to Scan(). The position moves back to the start of the
+// token.
 func (s *scanner) Unscan(tok int, b []byte) {
-	s.tmp.tok = tok
-	s.tmp.b = b
-	s.pos--
+	// The token is recorded again when it's scanned again
+	if s.recording && len(s.recorded) > 0 {
+		s.recorded = s.recorded[:len(s.recorded)-1]
+	}
+	s.unscan(tok, b)
+}
+
+func (s *scanner) unscan(tok int, b []byte) {
+	s.unscanned = append(s.unscanned, unscanned{tok, b, s.start, s.cur})
+	s.cur = s.start
+}
+
+// scanComment skips a // or /* */ comment after the "/".
+func (s *scanner) scanComment() error {
+	start := s.start
+	if err := s.read(); err != nil {
+		return err
+	}
+	switch s.c {
+	case '/':
+		for s.c != '\n' {
+			if err := s.read(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	case '*':
+		for star := false; ; star = s.c == '*' {
+			if err := s.read(); err == io.EOF {
+				return fmt.Errorf("unterminated comment at %s", start.Position)
+			} else if err != nil {
+				return err
+			}
+			if star && s.c == '/' {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected char at %s: %q", start.Position, '/')
+	}
+}
+
+// scanIdent reads an identifier, which is either true, false, null or an
+// unquoted object key.
+func (s *scanner) scanIdent() (int, []byte, error) {
+	start := s.start
+	n := 0
+	for s.c == '_' || s.c == '$' || unicode.IsLetter(s.c) || unicode.IsDigit(s.c) {
+		if n > bufSize-utf8.UTFMax {
+			return 0, nil, fmt.Errorf("identifier at %s is too long", start.Position)
+		}
+		n += utf8.EncodeRune(s.scratch[n:], s.c)
+		if err := s.read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, nil, err
+		}
+		if !(s.c == '_' || s.c == '$' || unicode.IsLetter(s.c) || unicode.IsDigit(s.c)) {
+			s.unread()
+			break
+		}
+	}
+	ident := s.scratch[0:n]
+	switch string(ident) {
+	case "true":
+		return TTRUE, nil, nil
+	case "false":
+		return TFALSE, nil, nil
+	case "null":
+		return TNULL, nil, nil
+	}
+	// Keys must be followed by a colon
+	tok, b, err := s.scanToken()
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	} else if err == io.EOF || tok != TCOLON {
+		return 0, nil, fmt.Errorf("unexpected identifier at %s: %s", start.Position, ident)
+	}
+	s.unscan(tok, b)
+	s.start = start
+	return TSTRING, ident, nil
 }
 
 // scanNumber reads a JSON number from the reader.
 func (s *scanner) scanNumber() (int, []byte, error) {
+	tok, b, err := s.scanNumberText()
+	if err == nil && s.strict && !validNumber(b) {
+		return 0, nil, fmt.Errorf("invalid number at %s: %s", s.start.Position, b)
+	}
+	return tok, b, err
+}
+
+// validNumber returns true if the number matches the JSON grammar:
+// -?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+func validNumber(b []byte) bool {
+	i := 0
+	digits := func() int {
+		start := i
+		for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+	if i < len(b) && b[i] == '-' {
+		i++
+	}
+	if i < len(b) && b[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return false
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '-' || b[i] == '+') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(b)
+}
+
+// scanNumberText reads the text of a number from the reader.
+func (s *scanner) scanNumberText() (int, []byte, error) {
 	var n int
 
 	if s.c == '-' {
@@ -257,7 +638,6 @@ func (s *scanner) scanScientific(n int) (int, []byte, error) {
 	if err := s.scanDigits(&n); err == io.EOF {
 		return TNUMBER, s.scratch[0:n], nil
 	} else if err != nil {
-		fmt.Println("Bad?")
 		return 0, nil, err
 	}
 	n++
@@ -282,12 +662,33 @@ func (s *scanner) scanDigits(n *int) error {
 	}
 }
 
-// scanString reads a quoted JSON string from the reader.
-func (s *scanner) scanString() (int, []byte, error) {
+// scanString reads a JSON string that's quoted with quote from the reader.
+func (s *scanner) scanString(quote rune) (int, []byte, error) {
+	if s.r == nil && s.tmpc == 0 {
+		if b, ok := s.sliceString(byte(quote)); ok {
+			return TSTRING, b, nil
+		}
+	}
 	var overflow []byte
 
 	var n int
+	// high is a high surrogate waiting for its low surrogate
+	var high rune
+	// unpaired replaces a lone surrogate with U+FFFD like encoding/json.
+	// Strict scanners reject it instead.
+	unpaired := func(r rune) error {
+		if s.strict {
+			return fmt.Errorf("lone surrogate in string at %s: \\u%04x", s.prev.Position, r)
+		}
+		n += utf8.EncodeRune(s.scratch[n:], utf8.RuneError)
+		return nil
+	}
 	for {
+		// Move the scratch into overflow before it can't fit another character
+		if n > bufSize-2*utf8.UTFMax {
+			overflow = append(overflow, s.scratch[0:n]...)
+			n = 0
+		}
 		if err := s.read(); err != nil {
 			return 0, nil, err
 		}
@@ -296,10 +697,22 @@ func (s *scanner) scanString() (int, []byte, error) {
 			if err := s.read(); err != nil {
 				return 0, nil, err
 			}
+			if high != 0 && s.c != 'u' {
+				if err := unpaired(high); err != nil {
+					return 0, nil, err
+				}
+				high = 0
+			}
 			switch s.c {
 			case '"':
 				s.scratch[n] = '"'
 				n++
+			case '\'':
+				if !s.single {
+					return 0, nil, fmt.Errorf("invalid escape character at %s: \\%c", s.start.Position, s.c)
+				}
+				s.scratch[n] = '\''
+				n++
 			case '\\':
 				s.scratch[n] = '\\'
 				n++
@@ -322,42 +735,42 @@ func (s *scanner) scanString() (int, []byte, error) {
 				s.scratch[n] = '\t'
 				n++
 			case 'u':
-				numeric := make([]byte, 4)
-				numericCount := 0
-			unicode_loop:
-				for {
-					if err := s.read(); err != nil {
+				r, err := s.scanHex()
+				if err != nil {
+					return 0, nil, err
+				}
+				// Combine surrogate pairs into a single rune
+				if high != 0 {
+					if r >= 0xDC00 && r <= 0xDFFF {
+						n += utf8.EncodeRune(s.scratch[n:], utf16.DecodeRune(high, r))
+						high = 0
+						continue
+					}
+					if err := unpaired(high); err != nil {
 						return 0, nil, err
 					}
-					switch {
-					case s.c >= '0' && s.c <= '9' || s.c >= 'a' && s.c <= 'f' || s.c >= 'A' && s.c <= 'F':
-						numeric[numericCount] = byte(s.c)
-						numericCount++
-						if numericCount == 4 {
-							var i int64
-							var err error
-							if i, err = strconv.ParseInt(string(numeric), 16, 32); err != nil {
-								return 0, nil, err
-							}
-							if i < utf8.RuneSelf {
-								s.scratch[n] = byte(i)
-								n++
-							} else {
-								encoded := utf8.EncodeRune(s.scratch[n:], rune(i))
-								n += encoded
-							}
-							break unicode_loop
-						}
-					default:
-						s.unread()
-						return 0, nil, fmt.Errorf("unexpected symbol in unicode escape: %c", s.c)
+					high = 0
+				}
+				switch {
+				case r >= 0xD800 && r < 0xDC00:
+					high = r
+				case utf16.IsSurrogate(r):
+					if err := unpaired(r); err != nil {
+						return 0, nil, err
 					}
+				default:
+					n += utf8.EncodeRune(s.scratch[n:], r)
 				}
 			default:
-				return 0, nil, fmt.Errorf("invalid escape character: \\%c", s.c)
+				return 0, nil, fmt.Errorf("invalid escape character at %s: \\%c", s.start.Position, s.c)
 			}
 
-		case '"':
+		case quote:
+			if high != 0 {
+				if err := unpaired(high); err != nil {
+					return 0, nil, err
+				}
+			}
 			if len(overflow) == 0 {
 				return TSTRING, s.scratch[0:n], nil
 			}
@@ -365,11 +778,16 @@ func (s *scanner) scanString() (int, []byte, error) {
 			return TSTRING, overflow, nil
 
 		default:
-			if s.c < utf8.RuneSelf {
-				if n == bufSize {
-					overflow = append(overflow, s.scratch[0:n]...)
-					n = 0
+			if high != 0 {
+				if err := unpaired(high); err != nil {
+					return 0, nil, err
 				}
+				high = 0
+			}
+			if s.c < 0x20 && s.strict {
+				return 0, nil, fmt.Errorf("unescaped control character in string at %s: %q", s.prev.Position, s.c)
+			}
+			if s.c < utf8.RuneSelf {
 				s.scratch[n] = byte(s.c)
 				n++
 			} else {
@@ -379,6 +797,59 @@ func (s *scanner) scanString() (int, []byte, error) {
 	}
 }
 
+// sliceString returns the string as a subslice of the input when it doesn't
+// need to be unescaped or validated rune by rune
+func (s *scanner) sliceString(quote byte) ([]byte, bool) {
+	runes := 0
+	for i := s.idx; i < s.buflen; runes++ {
+		b := s.buf[i]
+		if b == quote {
+			str := s.buf[s.idx:i]
+			s.idx = i + 1
+			s.cur.runes += runes
+			s.cur.Offset = i
+			s.cur.Column += runes
+			s.c = rune(quote)
+			s.prev = s.cur
+			s.advance(1)
+			return str, true
+		} else if b == '\\' || b < 0x20 {
+			return nil, false
+		} else if b < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s.buf[i:s.buflen])
+		if r == utf8.RuneError && size == 1 {
+			return nil, false
+		}
+		i += size
+	}
+	return nil, false
+}
+
+// scanHex reads the 4 hex digits of a \u escape
+func (s *scanner) scanHex() (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		if err := s.read(); err != nil {
+			return 0, err
+		}
+		switch {
+		case s.c >= '0' && s.c <= '9':
+			r = r<<4 | (s.c - '0')
+		case s.c >= 'a' && s.c <= 'f':
+			r = r<<4 | (s.c - 'a' + 10)
+		case s.c >= 'A' && s.c <= 'F':
+			r = r<<4 | (s.c - 'A' + 10)
+		default:
+			s.unread()
+			return 0, fmt.Errorf("unexpected symbol in unicode escape at %s: %c", s.start.Position, s.c)
+		}
+	}
+	return r, nil
+}
+
 // scanTrue reads the "true" token.
 func (s *scanner) scanTrue() (int, []byte, error) {
 	if err := s.expect('r'); err != nil {
@@ -433,122 +904,333 @@ func (s *scanner) ReadString(target *string) error {
 	switch tok {
 	case TSTRING:
 		*target = string(b)
-	case TNUMBER, TTRUE, TFALSE, TNULL:
+	case TNULL:
 		*target = ""
+	case TNUMBER:
+		if !s.lenient {
+			return s.typeError(tok, b, "string")
+		}
+		*target = string(b)
+	case TTRUE, TFALSE:
+		if !s.lenient {
+			return s.typeError(tok, b, "string")
+		}
+		*target = strconv.FormatBool(tok == TTRUE)
 	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected string", TokenName(tok), s.pos, string(b))
+		return s.typeError(tok, b, "string")
 	}
 	return nil
 }
 
-// ReadInt reads a token into an int variable.
-func (s *scanner) ReadInt(target *int) error {
+// readNumber scans a number token, coercing strings and booleans when lenient.
+// Null is returned as nil.
+func (s *scanner) readNumber() (int, []byte, error) {
 	tok, b, err := s.Scan()
 	if err != nil {
-		return err
+		return tok, nil, err
 	}
 	switch tok {
 	case TNUMBER:
-		n, _ := strconv.ParseInt(string(b), 10, 64)
-		*target = int(n)
-	case TSTRING, TTRUE, TFALSE, TNULL:
-		*target = 0
-	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
+		return tok, b, nil
+	case TNULL:
+		return tok, nil, nil
+	case TSTRING:
+		if s.lenient {
+			return tok, bytes.TrimSpace(b), nil
+		}
+	case TTRUE:
+		if s.lenient {
+			return tok, []byte("1"), nil
+		}
+	case TFALSE:
+		if s.lenient {
+			return tok, []byte("0"), nil
+		}
 	}
-	return nil
+	return tok, nil, s.typeError(tok, b, "number")
 }
 
-// ReadInt64 reads a token into an int64 variable.
-func (s *scanner) ReadInt64(target *int64) error {
+// ReadBase64 decodes a base64 string token into a byte slice. Null sets the
+// slice to nil.
+func (s *scanner) ReadBase64(target *[]byte, enc *base64.Encoding) error {
 	tok, b, err := s.Scan()
 	if err != nil {
 		return err
 	}
 	switch tok {
-	case TNUMBER:
-		n, _ := strconv.ParseInt(string(b), 10, 64)
-		*target = n
-	case TSTRING, TTRUE, TFALSE, TNULL:
-		*target = 0
+	case TSTRING:
+		// Decode straight from the token to avoid allocating a string
+		out := make([]byte, enc.DecodedLen(len(b)))
+		n, err := enc.Decode(out, b)
+		if err != nil {
+			return fmt.Errorf("invalid base64 at %s: %w", s.start.Position, err)
+		}
+		*target = out[:n]
+	case TNULL:
+		*target = nil
 	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
+		return s.typeError(tok, b, "string")
+	}
+	return nil
+}
+
+// ReadInt reads a token into an int variable.
+func (s *scanner) ReadInt(target *int) error {
+	n, err := s.readInt(strconv.IntSize, "int")
+	if err != nil {
+		return err
+	}
+	*target = int(n)
+	return nil
+}
+
+// ReadInt8 reads a token into an int8 variable.
+func (s *scanner) ReadInt8(target *int8) error {
+	n, err := s.readInt(8, "int8")
+	if err != nil {
+		return err
+	}
+	*target = int8(n)
+	return nil
+}
+
+// ReadInt16 reads a token into an int16 variable.
+func (s *scanner) ReadInt16(target *int16) error {
+	n, err := s.readInt(16, "int16")
+	if err != nil {
+		return err
+	}
+	*target = int16(n)
+	return nil
+}
+
+// ReadInt32 reads a token into an int32 variable.
+func (s *scanner) ReadInt32(target *int32) error {
+	n, err := s.readInt(32, "int32")
+	if err != nil {
+		return err
+	}
+	*target = int32(n)
+	return nil
+}
+
+// ReadInt64 reads a token into an int64 variable.
+func (s *scanner) ReadInt64(target *int64) error {
+	n, err := s.readInt(64, "int64")
+	if err != nil {
+		return err
 	}
+	*target = n
 	return nil
 }
 
 // ReadUint reads a token into an uint variable.
 func (s *scanner) ReadUint(target *uint) error {
-	tok, b, err := s.Scan()
+	n, err := s.readUint(strconv.IntSize, "uint")
 	if err != nil {
 		return err
 	}
-	switch tok {
-	case TNUMBER:
-		n, _ := strconv.ParseUint(string(b), 10, 64)
-		*target = uint(n)
-	case TSTRING, TTRUE, TFALSE, TNULL:
-		*target = 0
-	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
+	*target = uint(n)
+	return nil
+}
+
+// ReadUint8 reads a token into an uint8 variable.
+func (s *scanner) ReadUint8(target *uint8) error {
+	n, err := s.readUint(8, "uint8")
+	if err != nil {
+		return err
+	}
+	*target = uint8(n)
+	return nil
+}
+
+// ReadUint16 reads a token into an uint16 variable.
+func (s *scanner) ReadUint16(target *uint16) error {
+	n, err := s.readUint(16, "uint16")
+	if err != nil {
+		return err
+	}
+	*target = uint16(n)
+	return nil
+}
+
+// ReadUint32 reads a token into an uint32 variable.
+func (s *scanner) ReadUint32(target *uint32) error {
+	n, err := s.readUint(32, "uint32")
+	if err != nil {
+		return err
 	}
+	*target = uint32(n)
 	return nil
 }
 
 // ReadUint64 reads a token into an uint64 variable.
 func (s *scanner) ReadUint64(target *uint64) error {
-	tok, b, err := s.Scan()
+	n, err := s.readUint(64, "uint64")
 	if err != nil {
 		return err
 	}
-	switch tok {
-	case TNUMBER:
-		n, _ := strconv.ParseUint(string(b), 10, 64)
-		*target = n
-	case TSTRING, TTRUE, TFALSE, TNULL:
-		*target = 0
-	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
-	}
+	*target = n
 	return nil
 }
 
+// readInt reads a number token into a signed integer of the given bit size
+func (s *scanner) readInt(bits int, typ string) (int64, error) {
+	tok, b, err := s.readNumber()
+	if err != nil || b == nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(string(b), 10, bits)
+	if err == nil {
+		return n, nil
+	}
+	num, err := s.parseInteger(tok, b, bits, typ, err)
+	if err != nil {
+		return 0, err
+	}
+	// Signed integers range from -2^(bits-1) to 2^(bits-1)-1
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	min := new(big.Int).Neg(max)
+	if num.Cmp(min) < 0 || num.Cmp(max) >= 0 {
+		return 0, &NumberError{string(b), typ, s.start.Position, ErrOverflow}
+	}
+	return num.Int64(), nil
+}
+
+// readUint reads a number token into an unsigned integer of the given bit size
+func (s *scanner) readUint(bits int, typ string) (uint64, error) {
+	tok, b, err := s.readNumber()
+	if err != nil || b == nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(string(b), 10, bits)
+	if err == nil {
+		return n, nil
+	}
+	num, err := s.parseInteger(tok, b, bits, typ, err)
+	if err != nil {
+		return 0, err
+	}
+	if num.Sign() < 0 || num.BitLen() > bits {
+		return 0, &NumberError{string(b), typ, s.start.Position, ErrOverflow}
+	}
+	return num.Uint64(), nil
+}
+
+// parseInteger parses numbers that strconv rejected as integers, returning the
+// integer if it's only written differently (e.g. 1e3) and exact integers are
+// allowed
+func (s *scanner) parseInteger(tok int, b []byte, bits int, typ string, err error) (*big.Int, error) {
+	if errors.Is(err, strconv.ErrRange) {
+		return nil, &NumberError{string(b), typ, s.start.Position, ErrOverflow}
+	}
+	if !validNumber(b) {
+		// Coerced strings that aren't numbers, which big.Rat would otherwise
+		// parse as fractions or with a base prefix (e.g. 6/3 or 0x10)
+		return nil, s.typeError(tok, b, "number")
+	}
+	if !s.exact && bytes.ContainsAny(b, ".eE") {
+		// Only integers written as integers are allowed, so there's no need to
+		// work out the value
+		if i := bytes.IndexByte(b, '.'); i >= 0 && !bytes.ContainsAny(b, "eE") && len(bytes.TrimRight(b[i+1:], "0")) > 0 {
+			return nil, &NumberError{string(b), typ, s.start.Position, ErrFraction}
+		}
+		return nil, &NumberError{string(b), typ, s.start.Position, ErrNotInteger}
+	}
+	// Bound the exponent before big.Rat expands it (e.g. 1e999999). Integers of
+	// the given bit size have at most bits*log10(2)+1 digits.
+	digits, zero := integerDigits(b)
+	if zero {
+		return new(big.Int), nil
+	} else if digits > int64(bits)*30103/100000+1 {
+		return nil, &NumberError{string(b), typ, s.start.Position, ErrOverflow}
+	} else if digits <= 0 {
+		return nil, &NumberError{string(b), typ, s.start.Position, ErrFraction}
+	}
+	r, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		return nil, &NumberError{string(b), typ, s.start.Position, ErrOverflow}
+	} else if !r.IsInt() {
+		return nil, &NumberError{string(b), typ, s.start.Position, ErrFraction}
+	}
+	return r.Num(), nil
+}
+
+// integerDigits returns the number of digits before the decimal point once the
+// exponent of a valid number is applied (e.g. 2 for 1.28e1) and whether the
+// number is zero.
+func integerDigits(b []byte) (digits int64, zero bool) {
+	mantissa := b
+	var exp int64
+	if i := bytes.IndexAny(b, "eE"); i >= 0 {
+		mantissa = b[:i]
+		n, err := strconv.ParseInt(string(b[i+1:]), 10, 32)
+		if err != nil {
+			// Exponents beyond 32 bits saturate, which is plenty to overflow or
+			// underflow any integer
+			n = 1 << 31
+			if b[i+1] == '-' {
+				n = -n
+			}
+		}
+		exp = n
+	}
+	// Count the significant digits and the digits after the decimal point
+	var significant, fraction int64
+	point := false
+	for _, c := range mantissa {
+		switch {
+		case c == '.':
+			point = true
+		case c < '0' || c > '9':
+		default:
+			if point {
+				fraction++
+			}
+			if significant > 0 || c != '0' {
+				significant++
+			}
+		}
+	}
+	return significant + exp - fraction, significant == 0
+}
+
 // ReadFloat32 reads a token into a float32 variable.
 func (s *scanner) ReadFloat32(target *float32) error {
-	tok, b, err := s.Scan()
+	n, err := s.readFloat(32, "float32")
 	if err != nil {
 		return err
 	}
-	switch tok {
-	case TNUMBER:
-		n, _ := strconv.ParseFloat(string(b), 32)
-		*target = float32(n)
-	case TSTRING, TTRUE, TFALSE, TNULL:
-		*target = 0
-	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
-	}
+	*target = float32(n)
 	return nil
 }
 
 // ReadFloat64 reads a token into a float64 variable.
 func (s *scanner) ReadFloat64(target *float64) error {
-	tok, b, err := s.Scan()
+	n, err := s.readFloat(64, "float64")
 	if err != nil {
 		return err
 	}
-	switch tok {
-	case TNUMBER:
-		n, _ := strconv.ParseFloat(string(b), 64)
-		*target = n
-	case TSTRING, TTRUE, TFALSE, TNULL:
-		*target = 0
-	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
-	}
+	*target = n
 	return nil
 }
 
+// readFloat reads a number token into a float of the given bit size
+func (s *scanner) readFloat(bits int, typ string) (float64, error) {
+	tok, b, err := s.readNumber()
+	if err != nil || b == nil {
+		return 0, err
+	}
+	n, err := strconv.ParseFloat(string(b), bits)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, &NumberError{string(b), typ, s.start.Position, ErrOverflow}
+	} else if err != nil {
+		// Coerced strings that aren't numbers
+		return 0, s.typeError(tok, b, "number")
+	}
+	return n, nil
+}
+
 // ReadBool reads a token into a boolean variable.
 func (s *scanner) ReadBool(target *bool) error {
 	tok, b, err := s.Scan()
@@ -558,10 +1240,28 @@ func (s *scanner) ReadBool(target *bool) error {
 	switch tok {
 	case TTRUE:
 		*target = true
-	case TFALSE, TSTRING, TNUMBER, TNULL:
+	case TFALSE, TNULL:
 		*target = false
+	case TSTRING:
+		if !s.lenient {
+			return s.typeError(tok, b, "boolean")
+		}
+		v, err := strconv.ParseBool(string(bytes.TrimSpace(b)))
+		if err != nil {
+			return s.typeError(tok, b, "boolean")
+		}
+		*target = v
+	case TNUMBER:
+		if !s.lenient {
+			return s.typeError(tok, b, "boolean")
+		}
+		n, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return s.typeError(tok, b, "boolean")
+		}
+		*target = n != 0
 	default:
-		return fmt.Errorf("unexpected %s at %d: %s; expected number", TokenName(tok), s.pos, string(b))
+		return s.typeError(tok, b, "boolean")
 	}
 	return nil
 }
@@ -574,7 +1274,7 @@ func (s *scanner) ReadMap(target *map[string]interface{}) error {
 		*target = nil
 		return nil
 	} else if tok != TLBRACE {
-		return fmt.Errorf("unexpected %s at %d: %s; expected '{'", TokenName(tok), s.Pos(), string(b))
+		return fmt.Errorf("unexpected %s at %s: %s; expected '{'", TokenName(tok), s.start.Position, string(b))
 	}
 
 	// Create a new map.
@@ -593,15 +1293,17 @@ func (s *scanner) ReadMap(target *map[string]interface{}) error {
 			return nil
 		} else if tok == TCOMMA {
 			if index == 0 {
-				return fmt.Errorf("unexpected comma at %d", s.Pos())
+				return fmt.Errorf("unexpected comma at %s", s.start.Position)
 			}
 			if tok, b, err = s.Scan(); err != nil {
 				return err
 			}
+		} else if index > 0 && s.strict {
+			return fmt.Errorf("unexpected %s at %s: %s; expected ',' or '}'", TokenName(tok), s.start.Position, string(b))
 		}
 
 		if tok != TSTRING {
-			return fmt.Errorf("unexpected %s at %d: %s; expected '{' or string", TokenName(tok), s.Pos(), string(b))
+			return fmt.Errorf("unexpected %s at %s: %s; expected '{' or string", TokenName(tok), s.start.Position, string(b))
 		} else {
 			key = string(b)
 		}
@@ -610,7 +1312,7 @@ func (s *scanner) ReadMap(target *map[string]interface{}) error {
 		if tok, b, err := s.Scan(); err != nil {
 			return err
 		} else if tok != TCOLON {
-			return fmt.Errorf("unexpected %s at %d: %s; expected colon", TokenName(tok), s.Pos(), string(b))
+			return fmt.Errorf("unexpected %s at %s: %s; expected colon", TokenName(tok), s.start.Position, string(b))
 		}
 
 		// Read the next token.
@@ -644,7 +1346,7 @@ func (s *scanner) ReadMap(target *map[string]interface{}) error {
 			}
 			v[key] = arr
 		default:
-			return fmt.Errorf("unexpected %s at %d: %s", TokenName(tok), s.Pos(), string(b))
+			return fmt.Errorf("unexpected %s at %s: %s", TokenName(tok), s.start.Position, string(b))
 		}
 
 		index++
@@ -655,7 +1357,7 @@ func (s *scanner) ReadArray(target *[]interface{}) error {
 	if tok, b, err := s.Scan(); err != nil {
 		return err
 	} else if tok != TLBRACKET {
-		return fmt.Errorf("unexpected %s at %d: %s; expected '['", TokenName(tok), s.Pos(), string(b))
+		return fmt.Errorf("unexpected %s at %s: %s; expected '['", TokenName(tok), s.start.Position, string(b))
 	}
 
 	index := 0
@@ -667,11 +1369,13 @@ func (s *scanner) ReadArray(target *[]interface{}) error {
 			return nil
 		} else if tok == TCOMMA {
 			if index == 0 {
-				return fmt.Errorf("unexpected comma in array at %d", s.Pos())
+				return fmt.Errorf("unexpected comma in array at %s", s.start.Position)
 			}
 			if tok, b, err = s.Scan(); err != nil {
 				return err
 			}
+		} else if index > 0 && s.strict {
+			return fmt.Errorf("unexpected %s at %s: %s; expected ',' or ']'", TokenName(tok), s.start.Position, string(b))
 		}
 
 		var v interface{}
@@ -701,10 +1405,261 @@ func (s *scanner) ReadArray(target *[]interface{}) error {
 			}
 			v = arr
 		default:
-			return fmt.Errorf("unexpected %s at %d: %s", TokenName(tok), s.Pos(), string(b))
+			return fmt.Errorf("unexpected %s at %s: %s", TokenName(tok), s.start.Position, string(b))
 		}
 		*target = append(*target, v)
 
 		index++
 	}
 }
+
+// ReadInterface reads the next value of any type into an interface variable.
+func (s *scanner) ReadInterface(target *interface{}) error {
+	tok, b, err := s.Scan()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case TSTRING:
+		*target = string(b)
+	case TNUMBER:
+		*target, _ = strconv.ParseFloat(string(b), 64)
+	case TTRUE:
+		*target = true
+	case TFALSE:
+		*target = false
+	case TNULL:
+		*target = nil
+	case TLBRACE:
+		s.Unscan(tok, b)
+		var m map[string]interface{}
+		if err := s.ReadMap(&m); err != nil {
+			return err
+		}
+		*target = m
+	case TLBRACKET:
+		s.Unscan(tok, b)
+		arr := []interface{}{}
+		if err := s.ReadArray(&arr); err != nil {
+			return err
+		}
+		*target = arr
+	default:
+		return fmt.Errorf("unexpected %s at %s: %s", TokenName(tok), s.start.Position, string(b))
+	}
+	return nil
+}
+
+// RawValue reads the next value and returns it as compact JSON.
+func (s *scanner) RawValue() ([]byte, error) {
+	return s.appendValue(nil)
+}
+
+// Skip reads past the next value without keeping it. Nested values are
+// tracked with a stack of open objects and arrays rather than recursion.
+func (s *scanner) Skip() error {
+	var stack []int
+	for {
+		// Read a value
+		tok, b, err := s.Scan()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case TSTRING, TNUMBER, TTRUE, TFALSE, TNULL:
+		case TLBRACE:
+			if tok, b, err = s.Scan(); err != nil {
+				return err
+			} else if tok != TRBRACE {
+				if tok != TSTRING {
+					return fmt.Errorf("unexpected %s at %s: %s; expected string", TokenName(tok), s.start.Position, string(b))
+				} else if _, err := s.Expect(TCOLON); err != nil {
+					return err
+				}
+				stack = append(stack, TLBRACE)
+				continue
+			}
+		case TLBRACKET:
+			if tok, b, err = s.Scan(); err != nil {
+				return err
+			} else if tok != TRBRACKET {
+				s.Unscan(tok, b)
+				stack = append(stack, TLBRACKET)
+				continue
+			}
+		default:
+			return fmt.Errorf("unexpected %s at %s: %s", TokenName(tok), s.start.Position, string(b))
+		}
+		// Close the objects and arrays that end after the value
+	closing:
+		for {
+			if len(stack) == 0 {
+				return nil
+			}
+			tok, b, err := s.Scan()
+			if err != nil {
+				return err
+			}
+			switch {
+			case stack[len(stack)-1] == TLBRACE && tok == TRBRACE,
+				stack[len(stack)-1] == TLBRACKET && tok == TRBRACKET:
+				stack = stack[:len(stack)-1]
+			case tok != TCOMMA && stack[len(stack)-1] == TLBRACE:
+				return fmt.Errorf("unexpected %s at %s: %s; expected ',' or '}'", TokenName(tok), s.start.Position, string(b))
+			case tok != TCOMMA:
+				return fmt.Errorf("unexpected %s at %s: %s; expected ',' or ']'", TokenName(tok), s.start.Position, string(b))
+			case stack[len(stack)-1] == TLBRACE:
+				if tok, b, err = s.Scan(); err != nil {
+					return err
+				} else if tok != TSTRING {
+					return fmt.Errorf("unexpected %s at %s: %s; expected string", TokenName(tok), s.start.Position, string(b))
+				} else if _, err := s.Expect(TCOLON); err != nil {
+					return err
+				}
+				break closing
+			default:
+				break closing
+			}
+		}
+	}
+}
+
+// Lookahead reads the next value and puts its tokens back, so the value is
+// read again with the same positions. The returned scanner reads the same
+// tokens on its own, for looking inside the value before decoding it (e.g. to
+// find a union's discriminator).
+func (s *scanner) Lookahead() (Scanner, error) {
+	s.recording, s.recorded = true, nil
+	err := s.Skip()
+	tokens := s.recorded
+	s.recording, s.recorded = false, nil
+	if err != nil {
+		return nil, err
+	}
+	ahead := &scanner{
+		cur:      begin,
+		start:    begin,
+		lenient:  s.lenient,
+		exact:    s.exact,
+		strict:   s.strict,
+		relaxed:  s.relaxed,
+		unquoted: s.unquoted,
+		single:   s.single,
+	}
+	// Tokens are put back in reverse since they're read from the end
+	for i := len(tokens) - 1; i >= 0; i-- {
+		s.unscanned = append(s.unscanned, tokens[i])
+		ahead.unscanned = append(ahead.unscanned, tokens[i])
+	}
+	return ahead, nil
+}
+
+// appendValue reads the next value and appends it to out as compact JSON. Like
+// Skip, nested values are tracked with a stack rather than recursion.
+func (s *scanner) appendValue(out []byte) ([]byte, error) {
+	var stack []int
+	for {
+		// Read a value
+		tok, b, err := s.Scan()
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case TSTRING:
+			out = appendString(out, b)
+		case TNUMBER:
+			out = append(out, b...)
+		case TTRUE:
+			out = append(out, "true"...)
+		case TFALSE:
+			out = append(out, "false"...)
+		case TNULL:
+			out = append(out, "null"...)
+		case TLBRACE:
+			out = append(out, '{')
+			if tok, b, err = s.Scan(); err != nil {
+				return nil, err
+			} else if tok != TRBRACE {
+				if tok != TSTRING {
+					return nil, fmt.Errorf("unexpected %s at %s: %s; expected string", TokenName(tok), s.start.Position, string(b))
+				} else if _, err := s.Expect(TCOLON); err != nil {
+					return nil, err
+				}
+				out = append(appendString(out, b), ':')
+				stack = append(stack, TLBRACE)
+				continue
+			}
+			out = append(out, '}')
+		case TLBRACKET:
+			out = append(out, '[')
+			if tok, b, err = s.Scan(); err != nil {
+				return nil, err
+			} else if tok != TRBRACKET {
+				s.Unscan(tok, b)
+				stack = append(stack, TLBRACKET)
+				continue
+			}
+			out = append(out, ']')
+		default:
+			return nil, fmt.Errorf("unexpected %s at %s: %s", TokenName(tok), s.start.Position, string(b))
+		}
+		// Close the objects and arrays that end after the value
+	closing:
+		for {
+			if len(stack) == 0 {
+				return out, nil
+			}
+			tok, b, err := s.Scan()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case stack[len(stack)-1] == TLBRACE && tok == TRBRACE:
+				out = append(out, '}')
+				stack = stack[:len(stack)-1]
+			case stack[len(stack)-1] == TLBRACKET && tok == TRBRACKET:
+				out = append(out, ']')
+				stack = stack[:len(stack)-1]
+			case tok != TCOMMA && stack[len(stack)-1] == TLBRACE:
+				return nil, fmt.Errorf("unexpected %s at %s: %s; expected ',' or '}'", TokenName(tok), s.start.Position, string(b))
+			case tok != TCOMMA:
+				return nil, fmt.Errorf("unexpected %s at %s: %s; expected ',' or ']'", TokenName(tok), s.start.Position, string(b))
+			case stack[len(stack)-1] == TLBRACE:
+				if tok, b, err = s.Scan(); err != nil {
+					return nil, err
+				} else if tok != TSTRING {
+					return nil, fmt.Errorf("unexpected %s at %s: %s; expected string", TokenName(tok), s.start.Position, string(b))
+				} else if _, err := s.Expect(TCOLON); err != nil {
+					return nil, err
+				}
+				out = append(appendString(append(out, ','), b), ':')
+				break closing
+			default:
+				out = append(out, ',')
+				break closing
+			}
+		}
+	}
+}
+
+// appendString appends the quoted and escaped JSON string to out.
+func appendString(out []byte, b []byte) []byte {
+	out = append(out, '"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			out = append(out, '\\', c)
+		case c == '\n':
+			out = append(out, '\\', 'n')
+		case c == '\r':
+			out = append(out, '\\', 'r')
+		case c == '\t':
+			out = append(out, '\\', 't')
+		case c < 0x20:
+			out = append(out, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+		default:
+			out = append(out, c)
+		}
+	}
+	return append(out, '"')
+}
@@ -2,6 +2,10 @@ package scanner
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"strconv"
 	"strings"
@@ -130,6 +134,27 @@ func TestReadString(t *testing.T) {
 	is.Equal(v, "foo")
 }
 
+// Ensures that a base64 string can be read into bytes.
+func TestReadBase64(t *testing.T) {
+	is := is.New(t)
+	var v []byte
+	err := NewScanner(strings.NewReader(`"aGk/Pz4+"`)).ReadBase64(&v, base64.StdEncoding)
+	is.NoErr(err)
+	is.Equal(string(v), "hi??>>")
+	err = NewScanner(strings.NewReader(`"aGk_Pz4-"`)).ReadBase64(&v, base64.URLEncoding)
+	is.NoErr(err)
+	is.Equal(string(v), "hi??>>")
+	err = NewScanner(strings.NewReader(`null`)).ReadBase64(&v, base64.StdEncoding)
+	is.NoErr(err)
+	is.Equal(v, nil)
+	err = NewScanner(strings.NewReader(`"aGk_Pz4-"`)).ReadBase64(&v, base64.StdEncoding)
+	is.True(err != nil)
+	err = NewScanner(strings.NewReader(` 12`)).ReadBase64(&v, base64.StdEncoding)
+	var typeErr *TypeError
+	is.True(errors.As(err, &typeErr))
+	is.Equal(err.Error(), "unexpected number at line 1, column 2: 12; expected string")
+}
+
 // Ensures that strings largers than allocated buffer can be read.
 func TestReadHugeString(t *testing.T) {
 	is := is.New(t)
@@ -140,11 +165,23 @@ func TestReadHugeString(t *testing.T) {
 	is.Equal(v, huge)
 }
 
-// Ensures that a non-string value is read into a string field as blank.
+// Ensures that a non-string value returns a type error.
 func TestReadNonStringAsString(t *testing.T) {
 	is := is.New(t)
 	var v string
 	err := NewScanner(strings.NewReader(`12`)).ReadString(&v)
+	var typeErr *TypeError
+	is.True(errors.As(err, &typeErr))
+	is.Equal(typeErr.Token, TNUMBER)
+	is.Equal(typeErr.Expected, "string")
+	is.Equal(err.Error(), "unexpected number at line 1, column 1: 12; expected string")
+}
+
+// Ensures that null is read into a string field as blank.
+func TestReadNullAsString(t *testing.T) {
+	is := is.New(t)
+	v := "foo"
+	err := NewScanner(strings.NewReader(`null`)).ReadString(&v)
 	is.NoErr(err)
 	is.Equal(v, "")
 }
@@ -167,13 +204,52 @@ func TestReadInt(t *testing.T) {
 	is.Equal(v, 100)
 }
 
-// Ensures that a non-number value is read into an int field as zero.
+// Ensures that a non-number value returns a type error.
 func TestReadNonNumberAsInt(t *testing.T) {
 	is := is.New(t)
 	var v int
-	err := NewScanner(strings.NewReader(`"foo"`)).ReadInt(&v)
-	is.NoErr(err)
-	is.Equal(v, 0)
+	err := NewScanner(strings.NewReader(`"42"`)).ReadInt(&v)
+	var typeErr *TypeError
+	is.True(errors.As(err, &typeErr))
+	is.Equal(typeErr.Token, TSTRING)
+	is.Equal(typeErr.Expected, "number")
+	err = NewScanner(strings.NewReader(`1`)).ReadBool(new(bool))
+	is.True(errors.As(err, &typeErr))
+	err = NewScanner(strings.NewReader(`"true"`)).ReadBool(new(bool))
+	is.True(errors.As(err, &typeErr))
+}
+
+// Ensures that lenient scanners coerce values of the wrong type.
+func TestReadLenient(t *testing.T) {
+	is := is.New(t)
+	var i int
+	is.NoErr(NewScanner(strings.NewReader(`"42"`), Lenient()).ReadInt(&i))
+	is.Equal(i, 42)
+	is.NoErr(NewScanner(strings.NewReader(`true`), Lenient()).ReadInt(&i))
+	is.Equal(i, 1)
+	var u uint64
+	is.NoErr(NewScanner(strings.NewReader(`" 7 "`), Lenient()).ReadUint64(&u))
+	is.Equal(u, uint64(7))
+	var f float64
+	is.NoErr(NewScanner(strings.NewReader(`"1.5"`), Lenient()).ReadFloat64(&f))
+	is.Equal(f, 1.5)
+	var b bool
+	is.NoErr(NewScanner(strings.NewReader(`"true"`), Lenient()).ReadBool(&b))
+	is.Equal(b, true)
+	is.NoErr(NewScanner(strings.NewReader(`0`), Lenient()).ReadBool(&b))
+	is.Equal(b, false)
+	var s string
+	is.NoErr(NewScanner(strings.NewReader(`12.5`), Lenient()).ReadString(&s))
+	is.Equal(s, "12.5")
+	is.NoErr(NewScanner(strings.NewReader(`false`), Lenient()).ReadString(&s))
+	is.Equal(s, "false")
+	// Strings that can't be coerced are still errors
+	err := NewScanner(strings.NewReader(`"abc"`), Lenient()).ReadInt(&i)
+	var typeErr *TypeError
+	is.True(errors.As(err, &typeErr))
+	is.Equal(err.Error(), "unexpected string at line 1, column 1: abc; expected number")
+	err = NewScanner(strings.NewReader(`"maybe"`), Lenient()).ReadBool(&b)
+	is.True(errors.As(err, &typeErr))
 }
 
 // Ensures that an int64 can be read into a field.
@@ -282,6 +358,18 @@ func TestReadMapWithArray(t *testing.T) {
 	is.Equal(42.0, arr[1].(float64))
 }
 
+// Ensures that a nested value can be read as compact JSON.
+func TestRawValue(t *testing.T) {
+	is := is.New(t)
+	s := NewScanner(strings.NewReader(`{"a": [1, "b\"c", {"d": null}], "e": true} false`))
+	raw, err := s.RawValue()
+	is.NoErr(err)
+	is.Equal(string(raw), `{"a":[1,"b\"c",{"d":null}],"e":true}`)
+	tok, _, err := s.Scan()
+	is.NoErr(err)
+	is.Equal(tok, TFALSE)
+}
+
 func BenchmarkScanNumber(b *testing.B) {
 	withBuffer(b, "100", func(buf []byte) {
 		s := NewScanner(bytes.NewBuffer(buf))
@@ -416,3 +504,591 @@ func withBuffer(b *testing.B, value string, fn func([]byte)) {
 
 	b.SetBytes(int64(len(value)))
 }
+
+// Ensures that numbers that don't fit integer targets return errors.
+func TestReadIntInvalid(t *testing.T) {
+	is := is.New(t)
+	var i int
+	err := NewScanner(strings.NewReader(`1.5`)).ReadInt(&i)
+	is.True(errors.Is(err, ErrFraction))
+	is.Equal(err.Error(), "invalid int at line 1, column 1: 1.5 has a fraction")
+	err = NewScanner(strings.NewReader(`1e3`)).ReadInt(&i)
+	is.True(errors.Is(err, ErrNotInteger))
+	err = NewScanner(strings.NewReader(`99999999999999999999`)).ReadInt64(new(int64))
+	is.True(errors.Is(err, ErrOverflow))
+	is.Equal(err.Error(), "invalid int64 at line 1, column 1: 99999999999999999999 overflows")
+	err = NewScanner(strings.NewReader(`128`)).ReadInt8(new(int8))
+	is.True(errors.Is(err, ErrOverflow))
+	err = NewScanner(strings.NewReader(`-1`)).ReadUint(new(uint))
+	is.True(errors.Is(err, ErrOverflow))
+	err = NewScanner(strings.NewReader(`256`)).ReadUint8(new(uint8))
+	is.True(errors.Is(err, ErrOverflow))
+	err = NewScanner(strings.NewReader(`1e39`)).ReadFloat32(new(float32))
+	is.True(errors.Is(err, ErrOverflow))
+	err = NewScanner(strings.NewReader(`1e9999999`)).ReadInt(&i)
+	is.True(errors.Is(err, ErrNotInteger))
+	is.Equal(err.Error(), "invalid int at line 1, column 1: 1e9999999 isn't written as an integer")
+	// Lenient strings are only read as decimal numbers
+	var typeErr *TypeError
+	err = NewScanner(strings.NewReader(`"6/3"`), Lenient()).ReadInt(&i)
+	is.True(errors.As(err, &typeErr))
+	err = NewScanner(strings.NewReader(`"0x10"`), Lenient()).ReadUint(new(uint))
+	is.True(errors.As(err, &typeErr))
+	is.Equal(err.Error(), "unexpected string at line 1, column 1: 0x10; expected number")
+}
+
+// Ensures that integers at the limits of their size can be read.
+func TestReadIntLimits(t *testing.T) {
+	is := is.New(t)
+	var i8 int8
+	is.NoErr(NewScanner(strings.NewReader(`-128`)).ReadInt8(&i8))
+	is.Equal(i8, int8(-128))
+	var u16 uint16
+	is.NoErr(NewScanner(strings.NewReader(`65535`)).ReadUint16(&u16))
+	is.Equal(u16, uint16(65535))
+	var i32 int32
+	is.NoErr(NewScanner(strings.NewReader(`2147483647`)).ReadInt32(&i32))
+	is.Equal(i32, int32(2147483647))
+}
+
+// Ensures that exact integers can be read when allowed.
+func TestReadExactIntegers(t *testing.T) {
+	is := is.New(t)
+	var i int
+	is.NoErr(NewScanner(strings.NewReader(`1e3`), ExactIntegers()).ReadInt(&i))
+	is.Equal(i, 1000)
+	is.NoErr(NewScanner(strings.NewReader(`-2.0`), ExactIntegers()).ReadInt(&i))
+	is.Equal(i, -2)
+	var i8 int8
+	is.NoErr(NewScanner(strings.NewReader(`-1.28e2`), ExactIntegers()).ReadInt8(&i8))
+	is.Equal(i8, int8(-128))
+	err := NewScanner(strings.NewReader(`1.28e2`), ExactIntegers()).ReadInt8(&i8)
+	is.True(errors.Is(err, ErrOverflow))
+	err = NewScanner(strings.NewReader(`1.5e0`), ExactIntegers()).ReadInt(&i)
+	is.True(errors.Is(err, ErrFraction))
+	var u uint64
+	is.NoErr(NewScanner(strings.NewReader(`1.8e19`), ExactIntegers()).ReadUint64(&u))
+	is.Equal(u, uint64(18000000000000000000))
+	is.NoErr(NewScanner(strings.NewReader(`1000e-3`), ExactIntegers()).ReadInt(&i))
+	is.Equal(i, 1)
+	is.NoErr(NewScanner(strings.NewReader(`-0.0e9999999`), ExactIntegers()).ReadInt(&i))
+	is.Equal(i, 0)
+	err = NewScanner(strings.NewReader(`1e20`), ExactIntegers()).ReadUint64(&u)
+	is.True(errors.Is(err, ErrOverflow))
+	// Huge exponents are rejected without expanding them
+	err = NewScanner(strings.NewReader(`1e9999999`), ExactIntegers()).ReadInt(&i)
+	is.True(errors.Is(err, ErrOverflow))
+	is.Equal(err.Error(), "invalid int at line 1, column 1: 1e9999999 overflows")
+	err = NewScanner(strings.NewReader(`1e99999999999999999999`), ExactIntegers()).ReadInt(&i)
+	is.True(errors.Is(err, ErrOverflow))
+	err = NewScanner(strings.NewReader(`1e-9999999`), ExactIntegers()).ReadUint64(&u)
+	is.True(errors.Is(err, ErrFraction))
+}
+
+// strictValid reads a single value in strict mode and ensures nothing follows
+func strictValid(input string) error {
+	s := NewScanner(strings.NewReader(input), Strict())
+	if _, err := s.RawValue(); err != nil {
+		return err
+	}
+	if tok, b, err := s.Scan(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return errors.New("unexpected " + TokenName(tok) + " after value: " + string(b))
+	}
+	return nil
+}
+
+// Ensures that strict mode accepts the y_ cases from JSONTestSuite.
+func TestStrictAccept(t *testing.T) {
+	tests := map[string]string{
+		"y_array_arraysWithSpaces":             `[[]   ]`,
+		"y_array_empty":                        `[]`,
+		"y_array_heterogeneous":                `[null, 1, "1", {}]`,
+		"y_array_with_several_null":            `[1,null,null,null,2]`,
+		"y_array_with_trailing_space":          "[2] ",
+		"y_number_0e+1":                        `[0e+1]`,
+		"y_number_after_space":                 `[ 4]`,
+		"y_number_double_close_to_zero":        `[-0.000000000000000000000000000000000000000000000000000000000000000000000000000001]`,
+		"y_number_minus_zero":                  `[-0]`,
+		"y_number_negative_int":                `[-123]`,
+		"y_number_real_capital_e_neg_exp":      `[1E-2]`,
+		"y_number_real_fraction_exponent":      `[123.456e78]`,
+		"y_number_real_pos_exponent":           `[1e+2]`,
+		"y_object_basic":                       `{"asd":"sdf"}`,
+		"y_object_duplicated_key":              `{"a":"b","a":"c"}`,
+		"y_object_empty_key":                   `{"":0}`,
+		"y_object_extreme_numbers":             `{ "min": -1.0e+28, "max": 1.0e+28 }`,
+		"y_object_with_newlines":               "{\n\"a\": \"b\"\n}",
+		"y_string_accepted_surrogate_pair":     `["\uD801\udc37"]`,
+		"y_string_allowed_escapes":             `["\"\\\/\b\f\n\r\t"]`,
+		"y_string_escaped_control_character":   `["\u0012"]`,
+		"y_string_in_array_with_leading_space": `[ "asd"]`,
+		"y_string_nonCharacterInUTF-8_U+FFFF":  "[\"\xef\xbf\xbf\"]",
+		"y_string_unicode_U+10FFFE_nonchar":    `["\uDBFF\uDFFE"]`,
+		"y_string_utf8":                        `["€𝄞"]`,
+		"y_structure_lonely_false":             `false`,
+		"y_structure_lonely_int":               `42`,
+		"y_structure_lonely_string":            `"asd"`,
+		"y_structure_trailing_newline":         "[\"a\"]\n",
+		"y_structure_whitespace_array":         " [] ",
+	}
+	for name, input := range tests {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.NoErr(strictValid(input))
+		})
+	}
+}
+
+// Ensures that strict mode rejects the n_ cases from JSONTestSuite.
+func TestStrictReject(t *testing.T) {
+	tests := map[string]string{
+		"n_array_1_true_without_comma":              `[1 true]`,
+		"n_array_comma_after_close":                 `[""],`,
+		"n_array_double_comma":                      `[1,,2]`,
+		"n_array_extra_comma":                       `["",]`,
+		"n_array_inner_array_no_comma":              `[3[4]]`,
+		"n_array_missing_value":                     `[   , ""]`,
+		"n_array_unclosed":                          `[""`,
+		"n_number_-01":                              `[-01]`,
+		"n_number_-2.":                              `[-2.]`,
+		"n_number_.2e-3":                            `[.2e-3]`,
+		"n_number_0.e1":                             `[0.e1]`,
+		"n_number_2.e3":                             `[2.e3]`,
+		"n_number_+1":                               `[+1]`,
+		"n_number_0_capital_E":                      `[0E]`,
+		"n_number_minus_space_1":                    `[- 1]`,
+		"n_number_neg_int_starting_with_zero":       `[-012]`,
+		"n_number_with_leading_zero":                `[012]`,
+		"n_object_comma_instead_of_colon":           `{"x", null}`,
+		"n_object_missing_comma":                    `{"a":"b" "c":"d"}`,
+		"n_object_non_string_key":                   `{1:1}`,
+		"n_object_trailing_comma":                   `{"id":0,}`,
+		"n_object_single_quote":                     `{'a':0}`,
+		"n_string_1_surrogate_then_escape":          `["\uD800\"]`,
+		"n_string_escape_x":                         `["\x00"]`,
+		"n_string_incomplete_surrogate":             `["\uD834\uDd"]`,
+		"n_string_invalid_utf8_after_escape":        "[\"\\\xe5\"]",
+		"n_string_invalid-utf-8-in-escape":          "[\"\\u\xe5\"]",
+		"n_string_single_quote":                     `['single quote']`,
+		"n_string_unescaped_ctrl_char":              "[\"a\x00a\"]",
+		"n_string_unescaped_newline":                "[\"new\nline\"]",
+		"n_string_unescaped_tab":                    "[\"\t\"]",
+		"n_string_lone_low_surrogate":               `["\uDC00"]`,
+		"n_string_invalid_utf8":                     "[\"\xff\"]",
+		"n_string_overlong_sequence_2_bytes":        "[\"\xc0\xaf\"]",
+		"n_structure_capitalized_True":              `[True]`,
+		"n_structure_double_array":                  `[][]`,
+		"n_structure_no_data":                       ``,
+		"n_structure_null-byte-outside-string":      "[\x00]",
+		"n_structure_trailing_#":                    `{"a":"b"}#{}`,
+		"n_structure_UTF8_BOM_no_data":              "\xef\xbb\xbf",
+		"n_structure_whitespace_formfeed":           "[\f]",
+		"n_structure_whitespace_U+2060_word_joiner": "[\u2060]",
+	}
+	for name, input := range tests {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			is.True(strictValid(input) != nil)
+		})
+	}
+}
+
+// Ensures that multi-byte characters split across reads are decoded.
+func TestReadSplitRune(t *testing.T) {
+	is := is.New(t)
+	input := `"` + strings.Repeat("a", 4095) + `€"`
+	var s string
+	is.NoErr(NewScanner(strings.NewReader(input), Strict()).ReadString(&s))
+	is.Equal(s, input[1:len(input)-1])
+	input = `"` + strings.Repeat("€", 2000) + `"`
+	is.NoErr(NewScanner(strings.NewReader(input), Strict()).ReadString(&s))
+	is.Equal(s, input[1:len(input)-1])
+}
+
+// Ensures that surrogate pairs are combined and lone surrogates are replaced
+// like encoding/json.
+func TestReadSurrogates(t *testing.T) {
+	tests := []string{
+		`"😀"`,
+		`"a𐐷b"`,
+		`"\ud83d"`,
+		`"\ude00"`,
+		`"\ud83dx"`,
+		`"\ud83d\n"`,
+		`"\ud83dA"`,
+		`"\ud83d😀"`,
+		`"\ude00\ud83d"`,
+	}
+	for _, input := range tests {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			var expect string
+			is.NoErr(json.Unmarshal([]byte(input), &expect))
+			var actual string
+			is.NoErr(NewScanner(strings.NewReader(input)).ReadString(&actual))
+			is.Equal(actual, expect)
+		})
+	}
+}
+
+// Ensures that strict mode rejects lone surrogates.
+func TestReadSurrogatesStrict(t *testing.T) {
+	is := is.New(t)
+	var s string
+	is.NoErr(NewScanner(strings.NewReader(`"😀"`), Strict()).ReadString(&s))
+	is.Equal(s, "😀")
+	err := NewScanner(strings.NewReader(`"\ud83dA"`), Strict()).ReadString(&s)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `lone surrogate`))
+	err = NewScanner(strings.NewReader(`"\ude00"`), Strict()).ReadString(&s)
+	is.True(err != nil)
+}
+
+// Ensures that the bytes scanner returns strings without escapes as subslices
+// of the input.
+func TestBytesScannerZeroCopy(t *testing.T) {
+	is := is.New(t)
+	input := []byte(`{"name":"héllo","escaped":"a\nb"}`)
+	s := NewBytesScanner(input)
+	_, err := s.Expect(TLBRACE)
+	is.NoErr(err)
+	key, err := s.Expect(TSTRING)
+	is.NoErr(err)
+	is.Equal(string(key), "name")
+	is.Equal(&key[0], &input[2])
+	_, err = s.Expect(TCOLON)
+	is.NoErr(err)
+	value, err := s.Expect(TSTRING)
+	is.NoErr(err)
+	is.Equal(string(value), "héllo")
+	is.Equal(&value[0], &input[9])
+	_, err = s.Expect(TCOMMA)
+	is.NoErr(err)
+	_, err = s.Expect(TSTRING)
+	is.NoErr(err)
+	_, err = s.Expect(TCOLON)
+	is.NoErr(err)
+	value, err = s.Expect(TSTRING)
+	is.NoErr(err)
+	is.Equal(string(value), "a\nb")
+	_, err = s.Expect(TRBRACE)
+	is.NoErr(err)
+	_, _, err = s.Scan()
+	is.Equal(err, io.EOF)
+}
+
+// Ensures that the bytes scanner reads the same values as the reader scanner.
+func TestBytesScanner(t *testing.T) {
+	tests := []string{
+		`{"a":[1,-2.5e3,true,false,null],"b":{"c":"😀 €"},"d":""}`,
+		`"` + strings.Repeat("€", 3000) + `"`,
+		`[1,2`,
+		`"unterminated`,
+		`"\xff"`,
+		`"é`,
+	}
+	for _, input := range tests {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			var expect, actual interface{}
+			expectErr := NewScanner(strings.NewReader(input)).ReadInterface(&expect)
+			actualErr := NewBytesScanner([]byte(input)).ReadInterface(&actual)
+			is.Equal(actual, expect)
+			is.Equal(fmt.Sprint(actualErr), fmt.Sprint(expectErr))
+			r := NewScanner(strings.NewReader(input))
+			r.RawValue()
+			b := NewBytesScanner([]byte(input))
+			b.RawValue()
+			is.Equal(b.Pos(), r.Pos())
+		})
+	}
+}
+
+// Ensures that the scanner tracks the byte offset, line and column of tokens.
+func TestPosition(t *testing.T) {
+	input := "{\n  \"é\": [1,\n\t\"two\", true],\r\n  \"b\":null\n}"
+	expect := []Position{
+		{0, 1, 1},   // {
+		{4, 2, 3},   // "é"
+		{8, 2, 6},   // :
+		{10, 2, 8},  // [
+		{11, 2, 9},  // 1
+		{12, 2, 10}, // ,
+		{15, 3, 2},  // "two"
+		{20, 3, 7},  // ,
+		{22, 3, 9},  // true
+		{26, 3, 13}, // ]
+		{27, 3, 14}, // ,
+		{32, 4, 3},  // "b"
+		{35, 4, 6},  // :
+		{36, 4, 7},  // null
+		{41, 5, 1},  // }
+	}
+	scanners := map[string]Scanner{
+		"reader": NewScanner(strings.NewReader(input)),
+		"bytes":  NewBytesScanner([]byte(input)),
+	}
+	for name, s := range scanners {
+		s := s
+		t.Run(name, func(t *testing.T) {
+			is := is.New(t)
+			for _, position := range expect {
+				_, _, err := s.Scan()
+				is.NoErr(err)
+				is.Equal(s.Position(), position)
+			}
+			_, _, err := s.Scan()
+			is.Equal(err, io.EOF)
+		})
+	}
+}
+
+// Ensures that scan errors name the tokens and where they start.
+func TestErrorPosition(t *testing.T) {
+	tests := map[string]string{
+		`[1, tru]`:    `unexpected char at line 1, column 5: ']'; expected 'e'`,
+		`["a", "\x"]`: `invalid escape character at line 1, column 7: \x`,
+		`["\u12g4"]`:  `unexpected symbol in unicode escape at line 1, column 2: g`,
+	}
+	for input, expect := range tests {
+		input, expect := input, expect
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			err := NewScanner(strings.NewReader(input)).Skip()
+			is.True(err != nil)
+			is.Equal(err.Error(), expect)
+		})
+	}
+}
+
+// Ensures that Expect names the token it got and the token it wanted.
+func TestExpectError(t *testing.T) {
+	is := is.New(t)
+	s := NewScanner(strings.NewReader(`{"a": 1}`))
+	_, err := s.Expect(TLBRACE)
+	is.NoErr(err)
+	_, err = s.Expect(TSTRING)
+	is.NoErr(err)
+	_, err = s.Expect(TCOLON)
+	is.NoErr(err)
+	_, err = s.Expect(TLBRACE)
+	is.Equal(err.Error(), `unexpected number at line 1, column 7: 1; expected left brace`)
+}
+
+// Ensures that Unscan moves the position back to the start of the token.
+func TestPositionUnscan(t *testing.T) {
+	is := is.New(t)
+	s := NewScanner(strings.NewReader("[\n  123, 4]"))
+	_, err := s.Expect(TLBRACKET)
+	is.NoErr(err)
+	tok, b, err := s.Scan()
+	is.NoErr(err)
+	is.Equal(s.Pos(), 7)
+	s.Unscan(tok, b)
+	is.Equal(s.Pos(), 4)
+	is.Equal(s.Position(), Position{4, 2, 3})
+	_, err = s.Expect(TNUMBER)
+	is.NoErr(err)
+	is.Equal(s.Pos(), 7)
+	is.Equal(s.Position(), Position{4, 2, 3})
+	_, err = s.Expect(TCOMMA)
+	is.NoErr(err)
+	is.Equal(s.Position(), Position{7, 2, 6})
+}
+
+// Ensures that Lookahead reads the next value twice with the same positions.
+func TestLookahead(t *testing.T) {
+	is := is.New(t)
+	for _, s := range []Scanner{
+		NewScanner(strings.NewReader("[{\"a\": 1,\n \"b\": [\"\\u00e9\"]}, 3]")),
+		NewBytesScanner([]byte("[{\"a\": 1,\n \"b\": [\"\\u00e9\"]}, 3]")),
+	} {
+		_, err := s.Expect(TLBRACKET)
+		is.NoErr(err)
+		ahead, err := s.Lookahead()
+		is.NoErr(err)
+		type token struct {
+			tok      int
+			b        string
+			position Position
+		}
+		var tokens []token
+		for {
+			tok, b, err := ahead.Scan()
+			if err == io.EOF {
+				break
+			}
+			is.NoErr(err)
+			tokens = append(tokens, token{tok, string(b), ahead.Position()})
+		}
+		is.Equal(len(tokens), 11)
+		is.Equal(tokens[3], token{TNUMBER, "1", Position{7, 1, 8}})
+		is.Equal(tokens[8], token{TSTRING, "é", Position{17, 2, 8}})
+		for _, expect := range tokens {
+			tok, b, err := s.Scan()
+			is.NoErr(err)
+			is.Equal(token{tok, string(b), s.Position()}, expect)
+		}
+		_, err = s.Expect(TCOMMA)
+		is.NoErr(err)
+		var n int
+		is.NoErr(s.ReadInt(&n))
+		is.Equal(n, 3)
+		is.Equal(s.Position(), Position{29, 2, 20})
+	}
+}
+
+// Ensures that Skip reads past values of any type.
+func TestSkip(t *testing.T) {
+	tests := []string{
+		`"a\"b"`,
+		`-1.5e3`,
+		`true`,
+		`false`,
+		`null`,
+		`{}`,
+		`[]`,
+		`{"a":{"b":[1,{"c":[]},"d"]},"e":null}`,
+		`[[],[{}],[[1,2],{"a":[true]}]]`,
+	}
+	for _, input := range tests {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			s := NewScanner(strings.NewReader(`[` + input + `,42]`))
+			_, err := s.Expect(TLBRACKET)
+			is.NoErr(err)
+			is.NoErr(s.Skip())
+			_, err = s.Expect(TCOMMA)
+			is.NoErr(err)
+			b, err := s.Expect(TNUMBER)
+			is.NoErr(err)
+			is.Equal(string(b), "42")
+		})
+	}
+}
+
+// Ensures that Skip handles deeply nested values.
+func TestSkipDeep(t *testing.T) {
+	is := is.New(t)
+	depth := 100000
+	input := strings.Repeat(`{"a":[`, depth) + strings.Repeat(`]}`, depth)
+	s := NewBytesScanner([]byte(input))
+	is.NoErr(s.Skip())
+	_, _, err := s.Scan()
+	is.Equal(err, io.EOF)
+}
+
+// Ensures that RawValue handles deeply nested values without recursing.
+func TestRawValueDeep(t *testing.T) {
+	is := is.New(t)
+	depth := 100000
+	input := strings.Repeat(`{"a": [`, depth) + strings.Repeat(`] }`, depth)
+	s := NewBytesScanner([]byte(input))
+	raw, err := s.RawValue()
+	is.NoErr(err)
+	is.Equal(string(raw), strings.Repeat(`{"a":[`, depth)+strings.Repeat(`]}`, depth))
+	_, _, err = s.Scan()
+	is.Equal(err, io.EOF)
+}
+
+// Ensures that Skip rejects invalid values.
+func TestSkipInvalid(t *testing.T) {
+	tests := map[string]string{
+		`{"a" 1}`:   `unexpected number at line 1, column 6: 1; expected colon`,
+		`{"a":1 2}`: `unexpected number at line 1, column 8: 2; expected ',' or '}'`,
+		`[1 2]`:     `unexpected number at line 1, column 4: 2; expected ',' or ']'`,
+		`[1}`:       `unexpected right brace at line 1, column 3: }; expected ',' or ']'`,
+		`{1:2}`:     `unexpected number at line 1, column 2: 1; expected string`,
+		`{"a":1,}`:  `unexpected right brace at line 1, column 8: }; expected string`,
+		`]`:         `unexpected right bracket at line 1, column 1: ]`,
+		`[1,`:       `EOF`,
+	}
+	for input, expect := range tests {
+		input, expect := input, expect
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			err := NewScanner(strings.NewReader(input)).Skip()
+			is.True(err != nil)
+			is.Equal(err.Error(), expect)
+		})
+	}
+}
+
+// Ensures that relaxed scanners accept comments and trailing commas.
+func TestRelaxed(t *testing.T) {
+	tests := map[string]string{
+		"// comment\n{\"a\": 1}":                         `{"a":1}`,
+		"{\"a\": 1, // one\n \"b\": /* two */ 2}":        `{"a":1,"b":2}`,
+		"{\"a\": [1, 2,],}":                              `{"a":[1,2]}`,
+		"[1, {\"a\": true,},\n// trailing\n]":            `[1,{"a":true}]`,
+		"[\"//not a comment\", \"/*nor this*/\"] // end": `["//not a comment","/*nor this*/"]`,
+		"/* a\n * multi-line ** comment */ null":         `null`,
+	}
+	for input, expect := range tests {
+		input, expect := input, expect
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			for _, s := range []Scanner{
+				NewScanner(strings.NewReader(input), Relaxed()),
+				NewBytesScanner([]byte(input), Relaxed(), Strict()),
+			} {
+				raw, err := s.RawValue()
+				is.NoErr(err)
+				is.Equal(string(raw), expect)
+				_, _, err = s.Scan()
+				is.Equal(err, io.EOF)
+			}
+		})
+	}
+}
+
+// Ensures that relaxed scanners still reject invalid input.
+func TestRelaxedInvalid(t *testing.T) {
+	tests := map[string]string{
+		`[1 /* two`:   `unterminated comment at line 1, column 4`,
+		`[1 / 2]`:     `unexpected char at line 1, column 4: '/'`,
+		`[,]`:         `unexpected comma at line 1, column 2: ,`,
+		`[1,,]`:       `unexpected comma at line 1, column 4: ,`,
+		`{"a":1,,}`:   `unexpected comma at line 1, column 8: ,; expected string`,
+		`{"a":1 // }`: `EOF`,
+	}
+	for input, expect := range tests {
+		input, expect := input, expect
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			_, err := NewScanner(strings.NewReader(input), Relaxed()).RawValue()
+			is.True(err != nil)
+			is.Equal(err.Error(), expect)
+		})
+	}
+}
+
+// Ensures that unquoted keys and single-quoted strings are accepted when
+// enabled.
+func TestUnquotedKeysAndSingleQuotes(t *testing.T) {
+	is := is.New(t)
+	input := `{name: 'it\'s "here"', $id: 1, _ok: true, ünï: null, 'x': [false,],}`
+	s := NewScanner(strings.NewReader(input), Relaxed(), UnquotedKeys(), SingleQuotes())
+	raw, err := s.RawValue()
+	is.NoErr(err)
+	is.Equal(string(raw), `{"name":"it's \"here\"","$id":1,"_ok":true,"ünï":null,"x":[false]}`)
+	var m map[string]interface{}
+	is.NoErr(NewBytesScanner([]byte(input), Relaxed(), UnquotedKeys(), SingleQuotes()).ReadMap(&m))
+	is.Equal(m["name"], `it's "here"`)
+	// Identifiers are only keys
+	_, err = NewScanner(strings.NewReader(`{a: b}`), UnquotedKeys()).RawValue()
+	is.Equal(err.Error(), `unexpected identifier at line 1, column 5: b`)
+	// Both are off by default, where strict scanners reject them
+	_, err = NewScanner(strings.NewReader(`{a: 1}`), Strict()).RawValue()
+	is.True(err != nil)
+	_, err = NewScanner(strings.NewReader(`['a']`), Strict()).RawValue()
+	is.True(err != nil)
+}
@@ -0,0 +1,36 @@
+package scanner
+
+import "io"
+
+// Stream reads a sequence of top-level values from a reader, such as
+// newline-delimited JSON (NDJSON) or concatenated JSON.
+type Stream struct {
+	s      Scanner
+	record int
+}
+
+// NewStream initializes a new stream with a given reader.
+func NewStream(r io.Reader, options ...Option) *Stream {
+	return &Stream{s: NewScanner(r, options...)}
+}
+
+// Next moves to the next value and returns the scanner to read it with. It
+// returns io.EOF once there are no values left. The value must be read
+// completely before calling Next again.
+func (st *Stream) Next() (Scanner, error) {
+	tok, b, err := st.s.Scan()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	st.record++
+	if err != nil {
+		return nil, err
+	}
+	st.s.Unscan(tok, b)
+	return st.s, nil
+}
+
+// Record returns the number of the current value, starting at 1.
+func (st *Stream) Record() int {
+	return st.record
+}
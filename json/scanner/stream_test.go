@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// Ensures that a stream reads newline-delimited values until EOF.
+func TestStream(t *testing.T) {
+	is := is.New(t)
+	stream := NewStream(strings.NewReader("{\"a\":1}\n[2]\n\n\"three\" 4\n"))
+	var values []string
+	for {
+		s, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		is.NoErr(err)
+		raw, err := s.RawValue()
+		is.NoErr(err)
+		values = append(values, string(raw))
+		is.Equal(stream.Record(), len(values))
+	}
+	is.Equal(values, []string{`{"a":1}`, `[2]`, `"three"`, `4`})
+}
+
+// Ensures that an empty stream ends immediately.
+func TestStreamEmpty(t *testing.T) {
+	is := is.New(t)
+	stream := NewStream(strings.NewReader(" \n"))
+	_, err := stream.Next()
+	is.Equal(err, io.EOF)
+	is.Equal(stream.Record(), 0)
+}
+
+// Ensures that the record is counted when the next value is invalid.
+func TestStreamInvalid(t *testing.T) {
+	is := is.New(t)
+	stream := NewStream(strings.NewReader("1\n#"), Strict())
+	s, err := stream.Next()
+	is.NoErr(err)
+	var n int
+	is.NoErr(s.ReadInt(&n))
+	_, err = stream.Next()
+	is.True(err != nil)
+	is.Equal(stream.Record(), 2)
+}
@@ -3,32 +3,137 @@ package json
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/format"
+	"go/parser"
+	"go/types"
+	"io"
+	"reflect"
 	"strconv"
 	"strings"
 	"text/template"
+
+	"github.com/livebud/marshaler/json/patch"
 )
 
 type Unmarshaler struct {
 	// Import path we're generating code into
 	TargetPath string
 	// Find the type spec for the given import path and name
-	Find func(importPath string, name string) (ast.Expr, error)
+	Find func(importPath string, name string) (*ast.TypeSpec, error)
+	// Resolve the import path of a package name used within importPath
+	Resolve func(importPath string, name string) (string, error)
+	// Constants lists the constants declared with a type. When set, named
+	// strings and ints with a //json:enum directive only accept those values.
+	Constants func(importPath string, name string) ([]string, error)
+	// Constant evaluates a constant, used for array lengths (e.g. [Size]byte)
+	Constant func(importPath string, name string) (constant.Value, error)
 	// Add an import to the generated code
 	Import func(path string) (name string, err error)
+	// Also generate MergePatchJSON to apply JSON merge patches (RFC 7386)
+	MergePatch bool
+	// Also generate PatchJSON to apply JSON patches (RFC 6902)
+	Patch bool
+	// Also generate ProjectJSON to unmarshal only the selected paths
+	Project bool
+	// Also generate DecodeStream to unmarshal a stream of top-level values,
+	// such as newline-delimited JSON, from an io.Reader
+	Stream bool
+	// Also generate EachJSON to decode the array at EachPath one element at a
+	// time from an io.Reader, without keeping the whole array in memory
+	Each bool
+	// EachPath is the JSON pointer (RFC 6901) to the array within the type,
+	// where the empty pointer is a top-level array
+	EachPath string
+	// Reject extra elements in fixed-size arrays instead of dropping them
+	StrictArrays bool
+	// Coerce values of the wrong type (e.g. "42" into an int) instead of
+	// returning a scanner.TypeError
+	Lenient bool
+	// Accept integers written as exact numbers with exponents or fractions
+	// (e.g. 1e3 or 2.0)
+	ExactIntegers bool
+	// Reject input that isn't valid JSON according to RFC 8259
+	Strict bool
+	// Accept comments and trailing commas (JSONC) for files written by people
+	Relaxed bool
+	// Accept unquoted object keys like JSON5
+	UnquotedKeys bool
+	// Accept single-quoted strings like JSON5
+	SingleQuotes bool
+	// Derive keys from field names with snake, camel, kebab or pascal case.
+	// Types can override it with a //json:naming directive and tags still win.
+	Naming string
+	// Name of a func(key, alias string) in the target package that's called
+	// when a key is matched by one of its deprecated `alias:"..."` names
+	Deprecated string
+	// Fallback to encoding/json for fields whose type isn't implemented. When
+	// set, it's called with each field that fell back and the reason.
+	Fallback func(field string, err error)
+}
+
+// ErrNotImplemented is returned for types the generator can't handle yet
+var ErrNotImplemented = errors.New("not implemented")
+
+// lookupError wraps errors finding types, which can also fall back
+type lookupError struct {
+	err error
 }
 
+func (e *lookupError) Error() string { return e.err.Error() }
+func (e *lookupError) Unwrap() error { return e.err }
+
 //go:embed unmarshaler.gotext
 var unmarshalerTemplate string
 
-var generator = template.Must(template.New("unmarshaler").Parse(unmarshalerTemplate))
+var generator = template.Must(template.New("unmarshaler").Funcs(template.FuncMap{
+	"merging":    func() bool { return false },
+	"patching":   func() bool { return false },
+	"projecting": func() bool { return false },
+	"streaming":  func() bool { return false },
+	"iterating":  func() bool { return false },
+	// Replaced with the Unmarshaler's options when executing
+	"scannerOptions": func() string { return "" },
+	"strict":         func() bool { return false },
+	"pair":           newPair,
+	"shallow":        shallow,
+}).Parse(unmarshalerTemplate))
+
+// mergePatcher generates MergePatchJSON from the same templates
+var mergePatcher = template.Must(generator.Clone()).Funcs(template.FuncMap{
+	"merging": func() bool { return true },
+})
+
+// patcher generates PatchJSON from the same templates
+var patcher = template.Must(generator.Clone()).Funcs(template.FuncMap{
+	"patching": func() bool { return true },
+})
+
+// projector generates ProjectJSON from the same templates
+var projector = template.Must(generator.Clone()).Funcs(template.FuncMap{
+	"projecting": func() bool { return true },
+})
+
+// streamer generates DecodeStream from the same templates
+var streamer = template.Must(generator.Clone()).Funcs(template.FuncMap{
+	"streaming": func() bool { return true },
+})
+
+// iterator generates EachJSON from the same templates
+var iterator = template.Must(generator.Clone()).Funcs(template.FuncMap{
+	"iterating": func() bool { return true },
+})
+
+var patcherImports = []string{
+	"github.com/livebud/marshaler/json/patch",
+}
 
 var generatorImports = []string{
 	"fmt",
 	"github.com/livebud/marshaler/json/scanner",
-	"bytes",
 }
 
 // TODO: allow the import path name to be customized
@@ -44,11 +149,8 @@ func (u *Unmarshaler) typeName(importPath, name string) (string, error) {
 }
 
 func (u *Unmarshaler) Generate(importPath, name string) ([]byte, error) {
-	expr, err := u.Find(importPath, name)
-	if err != nil {
-		return nil, err
-	}
-	schema, err := fromExpr(expr, 0, "in")
+	b := &builder{u, importPath, map[string]bool{}, []string{name}, u.Naming, ""}
+	schema, err := b.fromNamed(name, 0, "in")
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +159,23 @@ func (u *Unmarshaler) Generate(importPath, name string) ([]byte, error) {
 			return nil, err
 		}
 	}
+	if u.Patch {
+		for _, importPath := range patcherImports {
+			if _, err := u.Import(importPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if u.Project {
+		if _, err := u.Import("github.com/livebud/marshaler/json/project"); err != nil {
+			return nil, err
+		}
+	}
+	if u.Stream || u.Each || u.Strict {
+		if _, err := u.Import("io"); err != nil {
+			return nil, err
+		}
+	}
 	typeName, err := u.typeName(importPath, name)
 	if err != nil {
 		return nil, err
@@ -64,47 +183,314 @@ func (u *Unmarshaler) Generate(importPath, name string) ([]byte, error) {
 	state := State{
 		Schema: schema,
 		Name:   typeName,
+		Root:   StructField{Type: schema, Target: deref("in")},
+	}
+	if u.Each {
+		if state.Each, err = findEach(schema, u.EachPath); err != nil {
+			return nil, err
+		}
 	}
 	code := new(bytes.Buffer)
-	if err := generator.Execute(code, state); err != nil {
+	if err := u.execute(code, generator, state); err != nil {
 		return nil, err
 	}
+	if u.MergePatch {
+		code.WriteString("\n\n")
+		if err := u.execute(code, mergePatcher, state); err != nil {
+			return nil, err
+		}
+	}
+	if u.Patch {
+		code.WriteString("\n\n")
+		if err := u.execute(code, patcher, state); err != nil {
+			return nil, err
+		}
+	}
+	if u.Project {
+		code.WriteString("\n\n")
+		if err := u.execute(code, projector, state); err != nil {
+			return nil, err
+		}
+	}
+	if u.Stream {
+		code.WriteString("\n\n")
+		if err := u.execute(code, streamer, state); err != nil {
+			return nil, err
+		}
+	}
+	if u.Each {
+		code.WriteString("\n\n")
+		if err := u.execute(code, iterator, state); err != nil {
+			return nil, err
+		}
+	}
 	return format.Source(code.Bytes())
 }
 
-func fromExpr(x ast.Expr, depth int, target string) (Type, error) {
+// execute the template with the scanner options
+func (u *Unmarshaler) execute(w io.Writer, t *template.Template, state State) error {
+	t, err := t.Clone()
+	if err != nil {
+		return err
+	}
+	t.Funcs(template.FuncMap{
+		"scannerOptions": u.scannerOptions,
+		"strict":         func() bool { return u.Strict },
+	})
+	return t.Execute(w, state)
+}
+
+// scannerOptions returns the options passed to scanner.NewBytesScanner
+func (u *Unmarshaler) scannerOptions() string {
+	options := ""
+	if u.Lenient {
+		options += ", scanner.Lenient()"
+	}
+	if u.ExactIntegers {
+		options += ", scanner.ExactIntegers()"
+	}
+	if u.Strict {
+		options += ", scanner.Strict()"
+	}
+	if u.Relaxed {
+		options += ", scanner.Relaxed()"
+	}
+	if u.UnquotedKeys {
+		options += ", scanner.UnquotedKeys()"
+	}
+	if u.SingleQuotes {
+		options += ", scanner.SingleQuotes()"
+	}
+	return options
+}
+
+// builder builds the schema for types declared within importPath
+type builder struct {
+	*Unmarshaler
+	importPath string
+	// Named types we're currently building, used to detect recursive types
+	building map[string]bool
+	// Fields leading to the type we're building, used for reporting
+	fields []string
+	// Naming strategy for the keys of the struct we're building
+	naming string
+	// Format from the tag of the field we're building (e.g. `format:"unix"`)
+	format string
+}
+
+func (b *builder) fromExpr(x ast.Expr, depth int, target string) (Type, error) {
 	switch x := x.(type) {
 	case *ast.Ident:
-		return fromIdent(x, depth, target)
+		return b.fromIdent(x, depth, target)
 	case *ast.StructType:
-		return fromStruct(x, depth, target)
+		return b.fromStruct(x, depth, target)
 	case *ast.MapType:
-		return fromMap(x, depth, target)
+		return b.fromMap(x, depth, target)
 	case *ast.ArrayType:
-		return fromArray(x, depth, target)
+		return b.fromArray(x, depth, target)
 	case *ast.StarExpr:
-		return fromStar(x, depth, target)
+		return b.fromStar(x, depth, target)
+	case *ast.SelectorExpr:
+		return b.fromSelector(x, depth, target)
+	case *ast.InterfaceType:
+		if len(x.Methods.List) > 0 {
+			return nil, fmt.Errorf("fromExpr: interface with methods %w", ErrNotImplemented)
+		}
+		return Interface{depth, target}, nil
 	default:
-		return nil, fmt.Errorf("fromExpr: %T not implemented", x)
+		return nil, fmt.Errorf("fromExpr: %T %w", x, ErrNotImplemented)
 	}
 }
 
-func fromStruct(s *ast.StructType, depth int, target string) (*Struct, error) {
-	var fields []StructField
+func (b *builder) fromStruct(s *ast.StructType, depth int, target string) (*Struct, error) {
+	st := &Struct{Depth: depth, Target: target}
+	// Keep the original declaration so anonymous structs keep their identity
+	decl := new(strings.Builder)
+	decl.WriteString("struct {\n")
+	// Selectors auto-dereference, so &in.A works for both `in *T` and `&in`
+	base := strings.TrimPrefix(target, "&")
+	// Names of the fields that use each key or alias
+	keys := map[string]string{}
 	for _, f := range s.Fields.List {
-		dataType, err := fromExpr(f.Type, depth+1, "&"+target+"."+f.Names[0].Name)
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("fromStruct: embedded field %s %w", types.ExprString(f.Type), ErrNotImplemented)
+		}
+		tag, err := parseTag(f.Tag)
 		if err != nil {
 			return nil, err
 		}
-		fields = append(fields, StructField{
-			Key:  f.Names[0].Name,
-			Type: dataType,
-		})
+		for _, name := range f.Names {
+			field := StructField{
+				Name:   name.Name,
+				Key:    name.Name,
+				Tag:    tag,
+				Target: base + "." + name.Name,
+			}
+			if tag.Name != "" {
+				field.Key = tag.Name
+			} else if field.Key, err = rename(b.naming, name.Name); err != nil {
+				return nil, err
+			}
+			if len(tag.Aliases) > 0 {
+				field.Deprecated = b.Deprecated
+			}
+			if tag.Ignore {
+				fmt.Fprintf(decl, "  %s %s %s\n", field.Name, types.ExprString(f.Type), tag)
+				continue
+			}
+			b.format = tag.Format
+			field.Type, err = b.fromField(f.Type, name.Name, depth+1, "&"+base+"."+name.Name)
+			b.format = ""
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(decl, "  %s %s %s\n", field.Name, field.Type, tag)
+			if tag.Has("remaining") {
+				if st.Remaining != nil {
+					return nil, fmt.Errorf("fromStruct: %s and %s are both tagged remaining", st.Remaining.Name, field.Name)
+				}
+				if !isRemaining(field.Type) {
+					return nil, fmt.Errorf("fromStruct: remaining field %s must be a map[string]any or map[string]json.RawMessage", field.Name)
+				}
+				st.Remaining = &field
+				continue
+			} else if tag.Has("present") {
+				if st.Present != nil {
+					return nil, fmt.Errorf("fromStruct: %s and %s are both tagged present", st.Present.Name, field.Name)
+				}
+				if !isPresent(field.Type) {
+					return nil, fmt.Errorf("fromStruct: present field %s must be a map[string]bool", field.Name)
+				}
+				st.Present = &field
+				continue
+			}
+			for _, key := range append([]string{field.Key}, tag.Aliases...) {
+				if other, ok := keys[key]; ok {
+					return nil, fmt.Errorf("fromStruct: %s and %s both use the key %q", other, field.Name, key)
+				}
+				keys[key] = field.Name
+			}
+			st.Fields = append(st.Fields, field)
+		}
 	}
-	return &Struct{fields, depth, target}, nil
+	decl.WriteString("}")
+	st.decl = decl.String()
+	return st, nil
 }
 
-func fromIdent(i *ast.Ident, depth int, target string) (Type, error) {
+// fromField builds the type of a field, falling back to encoding/json for
+// types that aren't implemented when enabled
+func (b *builder) fromField(x ast.Expr, name string, depth int, target string) (Type, error) {
+	b.fields = append(b.fields, name)
+	defer func() { b.fields = b.fields[:len(b.fields)-1] }()
+	dataType, err := b.fromExpr(x, depth, target)
+	if err == nil || b.Fallback == nil {
+		return dataType, err
+	}
+	var lookupErr *lookupError
+	if !errors.Is(err, ErrNotImplemented) && !errors.As(err, &lookupErr) {
+		return nil, err
+	}
+	typeName, qualifyErr := b.qualify(x)
+	if qualifyErr != nil {
+		return nil, err
+	}
+	if _, err := b.Import("encoding/json"); err != nil {
+		return nil, err
+	}
+	b.Fallback(strings.Join(b.fields, "."), err)
+	return Fallback{typeName, depth, target}, nil
+}
+
+// qualify the type expression for use within the generated code
+func (b *builder) qualify(x ast.Expr) (string, error) {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if types.Universe.Lookup(x.Name) != nil {
+			return x.Name, nil
+		}
+		return b.typeName(b.importPath, x.Name)
+	case *ast.SelectorExpr:
+		pkg, ok := x.X.(*ast.Ident)
+		if !ok {
+			return "", fmt.Errorf("qualify: %s %w", types.ExprString(x), ErrNotImplemented)
+		}
+		importPath, err := b.Resolve(b.importPath, pkg.Name)
+		if err != nil {
+			return "", err
+		}
+		return b.typeName(importPath, x.Sel.Name)
+	case *ast.StarExpr:
+		elem, err := b.qualify(x.X)
+		if err != nil {
+			return "", err
+		}
+		return "*" + elem, nil
+	case *ast.ArrayType:
+		elem, err := b.qualify(x.Elt)
+		if err != nil {
+			return "", err
+		}
+		if x.Len == nil {
+			return "[]" + elem, nil
+		}
+		return "[" + types.ExprString(x.Len) + "]" + elem, nil
+	case *ast.MapType:
+		key, err := b.qualify(x.Key)
+		if err != nil {
+			return "", err
+		}
+		value, err := b.qualify(x.Value)
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + value, nil
+	case *ast.ChanType:
+		elem, err := b.qualify(x.Value)
+		if err != nil {
+			return "", err
+		}
+		switch x.Dir {
+		case ast.SEND:
+			return "chan<- " + elem, nil
+		case ast.RECV:
+			return "<-chan " + elem, nil
+		default:
+			return "chan " + elem, nil
+		}
+	default:
+		return types.ExprString(x), nil
+	}
+}
+
+// isPresent returns true if the type can record which fields were present
+func isPresent(t Type) bool {
+	m, ok := t.(*Map)
+	if !ok {
+		return false
+	}
+	_, isString := m.Key.(String)
+	_, isBool := m.Value.(Bool)
+	return isString && isBool
+}
+
+// isRemaining returns true if the type can hold the remaining keys of a struct
+func isRemaining(t Type) bool {
+	m, ok := t.(*Map)
+	if !ok {
+		return false
+	} else if _, ok := m.Key.(String); !ok {
+		return false
+	}
+	switch m.Value.(type) {
+	case Interface, RawMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *builder) fromIdent(i *ast.Ident, depth int, target string) (Type, error) {
 	switch i.Name {
 	case "string":
 		return String{depth, target}, nil
@@ -114,55 +500,611 @@ func fromIdent(i *ast.Ident, depth int, target string) (Type, error) {
 		return Float64{depth, target}, nil
 	case "bool":
 		return Bool{depth, target}, nil
+	case "any":
+		return Interface{depth, target}, nil
+	case "int8", "int16", "int32", "int64", "rune",
+		"uint", "uint8", "uint16", "uint32", "uint64", "byte":
+		return Integer{i.Name, depth, deref(target)}, nil
+	}
+	// Other predeclared types aren't supported yet
+	if types.Universe.Lookup(i.Name) != nil {
+		return nil, fmt.Errorf("fromIdent: %q %w", i.Name, ErrNotImplemented)
+	}
+	return b.fromNamed(i.Name, depth, target)
+}
+
+// fromSelector builds a type from another package (e.g. json.RawMessage)
+func (b *builder) fromSelector(s *ast.SelectorExpr, depth int, target string) (Type, error) {
+	pkg, ok := s.X.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("fromSelector: %s %w", types.ExprString(s), ErrNotImplemented)
+	}
+	importPath, err := b.Resolve(b.importPath, pkg.Name)
+	if err != nil {
+		return nil, &lookupError{err}
+	}
+	switch importPath + "." + s.Sel.Name {
+	case "encoding/json.RawMessage":
+		typeName, err := b.typeName(importPath, s.Sel.Name)
+		if err != nil {
+			return nil, err
+		}
+		return RawMessage{typeName, depth, deref(target)}, nil
+	case "time.Time":
+		return b.fromTime(depth, target)
+	case "database/sql.NullString", "database/sql.NullInt64", "database/sql.NullInt32",
+		"database/sql.NullInt16", "database/sql.NullByte", "database/sql.NullFloat64",
+		"database/sql.NullBool", "database/sql.NullTime":
+		return b.fromNull(importPath, s.Sel.Name, depth, target)
+	case "time.Duration":
+		pkg, err := b.Import("time")
+		if err != nil {
+			return nil, err
+		}
+		return Duration{pkg, strings.Join(b.fields, "."), depth, deref(target)}, nil
+	}
+	// Build the type from within the other package
+	other := *b
+	other.importPath = importPath
+	return other.fromNamed(s.Sel.Name, depth, target)
+}
+
+// fromTime builds a time.Time decoded with the format from the field's tag:
+// rfc3339 (default), rfc3339nano, unix, unixmilli or a custom layout
+func (b *builder) fromTime(depth int, target string) (*Time, error) {
+	pkg, err := b.Import("time")
+	if err != nil {
+		return nil, err
+	}
+	t := &Time{
+		Pkg:    pkg,
+		Format: b.format,
+		Field:  strings.Join(b.fields, "."),
+		Depth:  depth,
+		Target: deref(target),
+	}
+	switch b.format {
+	case "", "rfc3339":
+		t.Layout = pkg + ".RFC3339"
+	case "rfc3339nano":
+		t.Layout = pkg + ".RFC3339Nano"
+	case "unix", "unixmilli":
+	default:
+		t.Layout = strconv.Quote(b.format)
+	}
+	return t, nil
+}
+
+// fromNull builds a database/sql Null type (e.g. sql.NullString), where null
+// is Valid=false
+func (b *builder) fromNull(importPath, name string, depth int, target string) (*Null, error) {
+	typeName, err := b.typeName(importPath, name)
+	if err != nil {
+		return nil, err
+	}
+	// Selectors auto-dereference, so &in.A.String works for both `in *T` and `&in`
+	base := strings.TrimPrefix(target, "&")
+	field := strings.TrimPrefix(name, "Null")
+	valueTarget := "&" + base + "." + field
+	var value Type
+	switch field {
+	case "String":
+		value = String{depth + 1, valueTarget}
+	case "Float64":
+		value = Float64{depth + 1, valueTarget}
+	case "Bool":
+		value = Bool{depth + 1, valueTarget}
+	case "Int64", "Int32", "Int16", "Byte":
+		value = Integer{strings.ToLower(field), depth + 1, deref(valueTarget)}
+	case "Time":
+		if value, err = b.fromTime(depth+1, valueTarget); err != nil {
+			return nil, err
+		}
+	}
+	return &Null{typeName, value, depth, deref(target), base}, nil
+}
+
+// fromNamed looks up a type declared in the builder's package and builds its
+// underlying type.
+func (b *builder) fromNamed(name string, depth int, target string) (*Named, error) {
+	if b.building[b.importPath+"."+name] {
+		return nil, fmt.Errorf("fromNamed: recursive type %q %w", name, ErrNotImplemented)
+	}
+	spec, err := b.Find(b.importPath, name)
+	if err != nil {
+		return nil, &lookupError{err}
+	}
+	typeName, err := b.typeName(b.importPath, name)
+	if err != nil {
+		return nil, err
+	}
+	b.building[b.importPath+"."+name] = true
+	defer delete(b.building, b.importPath+"."+name)
+	// Each type uses its own naming strategy, if any
+	naming := b.naming
+	b.naming = b.Naming
+	if directive, ok := findDirective(spec.Doc, "naming"); ok {
+		b.naming = directive
+	}
+	defer func() { b.naming = naming }()
+	var underlying Type
+	switch x := spec.Type.(type) {
+	case *ast.InterfaceType:
+		if _, ok := findDirective(spec.Doc, "union"); !ok && len(x.Methods.List) == 0 {
+			underlying = Interface{depth, target}
+			break
+		}
+		underlying, err = b.fromInterface(spec, depth, target)
+	default:
+		underlying, err = b.fromExpr(x, depth, target)
+		if err != nil {
+			return nil, err
+		}
+		underlying, err = b.fromEnum(spec, typeName, underlying, depth, target)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("fromIdent: %q not implemented", i.Name)
+	return &Named{typeName, underlying}, nil
 }
 
-func fromMap(m *ast.MapType, depth int, target string) (*Map, error) {
-	keyType, err := fromExpr(m.Key, depth+1, target)
+// fromEnum limits named strings and ints with a directive to the constants
+// declared with their type. Integer enums can use the constant names instead:
+//
+//	//json:enum names
+//	type Level int
+//
+// Types without the directive accept any value, since typed constants are
+// also used for defaults (e.g. `const DefaultPort Port = 8080`).
+func (b *builder) fromEnum(spec *ast.TypeSpec, typeName string, underlying Type, depth int, target string) (Type, error) {
+	directive, ok := findDirective(spec.Doc, "enum")
+	if !ok || b.Constants == nil {
+		return underlying, nil
+	}
+	switch underlying.(type) {
+	case String, Int, Integer:
+	default:
+		return nil, fmt.Errorf("fromEnum: %q must be a string or int to be an enum", spec.Name.Name)
+	}
+	names, err := b.Constants(b.importPath, spec.Name.Name)
+	if err != nil {
+		return nil, &lookupError{err}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("fromEnum: no constants declared for %q", spec.Name.Name)
+	}
+	enum := &Enum{
+		Name:       typeName,
+		Underlying: underlying,
+		Depth:      depth,
+		Target:     deref(target),
+	}
+	switch directive {
+	case "":
+	case "names":
+		if _, ok := underlying.(String); ok {
+			return nil, fmt.Errorf("fromEnum: only int enums can use names, but %q is a string", spec.Name.Name)
+		}
+		enum.Names = true
+	default:
+		return nil, fmt.Errorf("fromEnum: unknown //json:enum %q on %q", directive, spec.Name.Name)
+	}
+	// Constants share the qualifier of their type (e.g. pkg.Active)
+	qualifier := strings.TrimSuffix(typeName, spec.Name.Name)
+	for _, name := range names {
+		enum.Constants = append(enum.Constants, EnumConstant{name, qualifier + name})
+	}
+	return enum, nil
+}
+
+// fromInterface builds a tagged union from the directive on the interface:
+//
+//	//json:union kind circle=Circle square=*Square
+//	type Shape interface{ ... }
+func (b *builder) fromInterface(spec *ast.TypeSpec, depth int, target string) (*Union, error) {
+	directive, ok := findDirective(spec.Doc, "union")
+	if !ok {
+		return nil, fmt.Errorf("fromInterface: missing //json:union directive on %q", spec.Name.Name)
+	}
+	fields := strings.Fields(directive)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("fromInterface: expected //json:union <key> <value>=<type>... on %q", spec.Name.Name)
+	}
+	union := &Union{
+		Key:    fields[0],
+		Depth:  depth,
+		Target: deref(target),
+	}
+	for _, field := range fields[1:] {
+		value, typeExpr, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("fromInterface: invalid union variant %q on %q", field, spec.Name.Name)
+		}
+		expr, err := parser.ParseExpr(typeExpr)
+		if err != nil {
+			return nil, fmt.Errorf("fromInterface: invalid union variant %q on %q. %w", field, spec.Name.Name, err)
+		}
+		// Static target because it's defined in the template
+		dataType, err := b.fromExpr(expr, depth+1, "&val"+strconv.Itoa(depth))
+		if err != nil {
+			return nil, err
+		}
+		variant := structOf(dataType)
+		if variant == nil {
+			return nil, fmt.Errorf("fromInterface: union variant %q on %q must be a struct", typeExpr, spec.Name.Name)
+		}
+		// The variant doesn't need a field for the discriminator, but it still
+		// needs to accept the key
+		if !variant.HasKey(union.Key) {
+			variant.Discriminator = union.Key
+		}
+		union.Variants = append(union.Variants, UnionVariant{value, dataType})
+	}
+	return union, nil
+}
+
+func (b *builder) fromMap(m *ast.MapType, depth int, target string) (*Map, error) {
+	keyType, keyKind, err := b.fromMapKey(m.Key, depth+1, target)
 	if err != nil {
 		return nil, err
 	}
 	// Static target because it's defined in the template
-	valueType, err := fromExpr(m.Value, depth+1, "&val"+strconv.Itoa(depth))
+	valueType, err := b.fromExpr(m.Value, depth+1, "&val"+strconv.Itoa(depth))
 	if err != nil {
 		return nil, err
 	}
 	// For maps, we pull the value out of the target first and you Go doesn't
 	// support `val := &target["key"]`, so we do `val := target["key"]` and
 	// then `&val` instead.
-	newTarget := strings.TrimPrefix(target, "&")
-	return &Map{keyType, valueType, depth, newTarget}, nil
+	newTarget := deref(target)
+	return &Map{keyType, keyKind, valueType, depth, newTarget}, nil
+}
+
+// fromMapKey builds the key of a map and how it's parsed from the JSON key,
+// matching encoding/json: strings are converted, integers are parsed and other
+// types use their UnmarshalText method
+func (b *builder) fromMapKey(x ast.Expr, depth int, target string) (Type, string, error) {
+	keyType, err := b.fromExpr(x, depth, target)
+	if err != nil {
+		var lookupErr *lookupError
+		if !errors.Is(err, ErrNotImplemented) && !errors.As(err, &lookupErr) {
+			return nil, "", err
+		}
+		// Assume types we can't build implement encoding.TextUnmarshaler
+		name, qualifyErr := b.qualify(x)
+		if qualifyErr != nil {
+			return nil, "", err
+		}
+		return Text{name}, "text", nil
+	}
+	underlying := keyType
+	if named, ok := keyType.(*Named); ok {
+		// Named keys may implement encoding.TextUnmarshaler
+		if _, err := b.Import("encoding"); err != nil {
+			return nil, "", err
+		}
+		underlying = named.Underlying
+		if enum, ok := underlying.(*Enum); ok {
+			underlying = enum.Underlying
+		}
+	}
+	switch u := underlying.(type) {
+	case String:
+		return keyType, "string", nil
+	case Int, Integer:
+		if _, err := b.Import("strconv"); err != nil {
+			return nil, "", err
+		}
+		if i, ok := u.(Integer); ok && strings.HasPrefix(i.Base(), "u") {
+			return keyType, "uint", nil
+		}
+		return keyType, "int", nil
+	case *Struct, *Array:
+		return Text{keyType.String()}, "text", nil
+	default:
+		return nil, "", fmt.Errorf("fromMap: %s map keys %w", types.ExprString(x), ErrNotImplemented)
+	}
 }
 
-func fromArray(a *ast.ArrayType, depth int, target string) (*Array, error) {
+func (b *builder) fromArray(a *ast.ArrayType, depth int, target string) (Type, error) {
+	// Like encoding/json, byte slices are base64 strings
+	if elt, ok := a.Elt.(*ast.Ident); ok && a.Len == nil && (elt.Name == "byte" || elt.Name == "uint8") {
+		return b.fromBytes(depth, target)
+	}
 	// Static target because it's defined in the template
-	dataType, err := fromExpr(a.Elt, depth+1, "&val"+strconv.Itoa(depth))
+	dataType, err := b.fromExpr(a.Elt, depth+1, "&val"+strconv.Itoa(depth))
 	if err != nil {
 		return nil, err
 	}
 	// For arrays, we pull the value out of the target first and you Go doesn't
 	// support `&target := append(&target, val)`, so we do
 	// `target := append(target, val)` instead.
-	newTarget := strings.TrimPrefix(target, "&")
-	return &Array{dataType, depth, newTarget}, nil
+	newTarget := deref(target)
+	if a.Len == nil {
+		return &Array{dataType, false, 0, b.StrictArrays, depth, newTarget}, nil
+	}
+	length, err := b.arrayLength(a.Len)
+	if err != nil {
+		return nil, err
+	}
+	return &Array{dataType, true, length, b.StrictArrays, depth, newTarget}, nil
+}
+
+// fromBytes builds a byte slice that's encoded with base64, where the field's
+// tag chooses the base64 (default) or base64url encoding
+func (b *builder) fromBytes(depth int, target string) (*Bytes, error) {
+	pkg, err := b.Import("encoding/base64")
+	if err != nil {
+		return nil, err
+	}
+	switch b.format {
+	case "", "base64":
+		return &Bytes{pkg + ".StdEncoding", depth, target}, nil
+	case "base64url":
+		return &Bytes{pkg + ".URLEncoding", depth, target}, nil
+	default:
+		return nil, fmt.Errorf("fromBytes: unknown format %q, expected base64 or base64url", b.format)
+	}
+}
+
+// arrayLength evaluates the length of a fixed-size array (e.g. [16]byte or
+// [Size]byte)
+func (b *builder) arrayLength(x ast.Expr) (int, error) {
+	var value constant.Value
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		value = constant.MakeFromLiteral(x.Value, x.Kind, 0)
+	case *ast.Ident:
+		if b.Constant == nil {
+			return 0, fmt.Errorf("fromArray: unable to evaluate length %s %w", x.Name, ErrNotImplemented)
+		}
+		v, err := b.Constant(b.importPath, x.Name)
+		if err != nil {
+			return 0, &lookupError{err}
+		}
+		value = v
+	case *ast.SelectorExpr:
+		pkg, ok := x.X.(*ast.Ident)
+		if !ok || b.Constant == nil {
+			return 0, fmt.Errorf("fromArray: unable to evaluate length %s %w", types.ExprString(x), ErrNotImplemented)
+		}
+		importPath, err := b.Resolve(b.importPath, pkg.Name)
+		if err != nil {
+			return 0, &lookupError{err}
+		}
+		v, err := b.Constant(importPath, x.Sel.Name)
+		if err != nil {
+			return 0, &lookupError{err}
+		}
+		value = v
+	default:
+		return 0, fmt.Errorf("fromArray: unable to evaluate length %s %w", types.ExprString(x), ErrNotImplemented)
+	}
+	length, ok := constant.Int64Val(constant.ToInt(value))
+	if !ok || length < 0 {
+		return 0, fmt.Errorf("fromArray: invalid array length %s", types.ExprString(x))
+	}
+	return int(length), nil
 }
 
-func fromStar(s *ast.StarExpr, depth int, target string) (*Star, error) {
+func (b *builder) fromStar(s *ast.StarExpr, depth int, target string) (*Star, error) {
 	// Static target because it's defined in the template
-	dataType, err := fromExpr(s.X, depth+1, "val"+strconv.Itoa(depth))
+	dataType, err := b.fromExpr(s.X, depth+1, "val"+strconv.Itoa(depth))
 	if err != nil {
 		return nil, err
 	}
 	// For stars, we pull the value out of the target first and you Go doesn't
 	// support `&target := val`, so we do `target := val` instead.
-	newTarget := strings.TrimPrefix(target, "&")
+	newTarget := deref(target)
 	return &Star{dataType, depth, newTarget}, nil
 }
 
+// Tag is the parsed json struct tag (e.g. `json:"name,omitempty"`)
+type Tag struct {
+	Name    string
+	Options []string
+	// Ignore is true for `json:"-"`
+	Ignore bool
+	// Aliases are other accepted keys (e.g. `alias:"fullName,full_name"`)
+	Aliases []string
+	// Format of the value (e.g. `format:"unix"`)
+	Format string
+	raw    string
+}
+
+func parseTag(lit *ast.BasicLit) (tag Tag, err error) {
+	if lit == nil {
+		return tag, nil
+	}
+	tag.raw = lit.Value
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return tag, err
+	}
+	for _, alias := range strings.Split(reflect.StructTag(value).Get("alias"), ",") {
+		if alias != "" {
+			tag.Aliases = append(tag.Aliases, alias)
+		}
+	}
+	tag.Format = reflect.StructTag(value).Get("format")
+	json := reflect.StructTag(value).Get("json")
+	if json == "-" {
+		tag.Ignore = true
+		return tag, nil
+	}
+	parts := strings.Split(json, ",")
+	tag.Name = parts[0]
+	for _, option := range parts[1:] {
+		if option != "" {
+			tag.Options = append(tag.Options, option)
+		}
+	}
+	return tag, nil
+}
+
+// Has returns true if the tag has the option
+func (t Tag) Has(option string) bool {
+	for _, o := range t.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Tag) String() string {
+	return t.raw
+}
+
+// findDirective finds a //json:<name> directive within the doc comment and
+// returns the rest of the line
+func findDirective(doc *ast.CommentGroup, name string) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	prefix := "//json:" + name
+	for _, comment := range doc.List {
+		if comment.Text == prefix {
+			return "", true
+		}
+		if strings.HasPrefix(comment.Text, prefix+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(comment.Text, prefix)), true
+		}
+	}
+	return "", false
+}
+
+// deref turns a target into an assignable expression. Targets are either the
+// address of a value (e.g. &in.A) or a pointer (e.g. in). Pointers are
+// wrapped in parentheses so they can be indexed (e.g. (*in)[0]).
+func deref(target string) string {
+	if strings.HasPrefix(target, "&") {
+		return strings.TrimPrefix(target, "&")
+	}
+	return "(*" + target + ")"
+}
+
+// shallow returns true if the type can be copied by assignment. Patches only
+// walk into structs, maps, arrays and pointers, so other values are replaced
+// whole and can be shared.
+func shallow(t Type) bool {
+	switch t := t.(type) {
+	case *Named:
+		return shallow(t.Underlying)
+	case *Struct:
+		for _, field := range t.Fields {
+			if !shallow(field.Type) {
+				return false
+			}
+		}
+		return true
+	case *Array:
+		return t.Fixed && shallow(t.Elt)
+	case *Map, *Star:
+		return false
+	default:
+		return true
+	}
+}
+
+// Pair is two values of the same type, used to generate code that copies or
+// compares them
+type Pair struct {
+	Type  Type
+	A, B  string
+	Depth int
+}
+
+func newPair(t Type, a, b string) Pair {
+	return Pair{t, paren(a), paren(b), 0}
+}
+
+// With returns the pair of values nested within the pair's values
+func (p Pair) With(t Type, a, b string) Pair {
+	return Pair{t, paren(a), paren(b), p.Depth + 1}
+}
+
+// paren wraps dereferences so they can be indexed (e.g. (*in)[0])
+func paren(x string) string {
+	if strings.HasPrefix(x, "*") {
+		return "(" + x + ")"
+	}
+	return x
+}
+
+// structOf returns the struct underlying a type, if any
+func structOf(t Type) *Struct {
+	switch t := t.(type) {
+	case *Struct:
+		return t
+	case *Named:
+		return structOf(t.Underlying)
+	case *Star:
+		return structOf(t.X)
+	default:
+		return nil
+	}
+}
+
 type State struct {
 	Schema Type
 	Name   string
+	// Root is the top-level value as a field, used when patching
+	Root StructField
+	// Each is the array that's iterated over, used when iterating
+	Each *Each
+}
+
+// Each is the array at the end of a path of object keys
+type Each struct {
+	Pointer string
+	Keys    []string
+	Array   *Array
+}
+
+// Key returns the next key leading to the array
+func (e *Each) Key() string {
+	return e.Keys[0]
+}
+
+// Next returns the path after the next key
+func (e *Each) Next() *Each {
+	return &Each{e.Pointer, e.Keys[1:], e.Array}
+}
+
+// findEach finds the array at the JSON pointer within t
+func findEach(t Type, pointer string) (*Each, error) {
+	keys, err := patch.Split(pointer)
+	if err != nil {
+		return nil, fmt.Errorf("findEach: %w", err)
+	}
+	each := &Each{Pointer: pointer, Keys: keys}
+	for {
+		switch x := t.(type) {
+		case *Named:
+			t = x.Underlying
+		case *Star:
+			t = x.X
+		case *Array:
+			if len(keys) > 0 {
+				return nil, fmt.Errorf("findEach: %q is an array, not an object", pointer)
+			}
+			each.Array = x
+			return each, nil
+		case *Struct:
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("findEach: %q is an object, not an array", pointer)
+			}
+			field, ok := x.Field(keys[0])
+			if !ok {
+				return nil, fmt.Errorf("findEach: %q doesn't match a field", pointer)
+			}
+			t = field.Type
+			keys = keys[1:]
+		default:
+			return nil, fmt.Errorf("findEach: %q is a %s, not an array", pointer, t)
+		}
+	}
 }
 
 type Type interface {
@@ -170,14 +1112,26 @@ type Type interface {
 	String() string
 }
 
-func (String) Type() string  { return "string" }
-func (Int) Type() string     { return "int" }
-func (Float64) Type() string { return "float64" }
-func (Bool) Type() string    { return "bool" }
-func (Struct) Type() string  { return "struct" }
-func (Array) Type() string   { return "array" }
-func (Map) Type() string     { return "map" }
-func (Star) Type() string    { return "star" }
+func (String) Type() string     { return "string" }
+func (Int) Type() string        { return "int" }
+func (Float64) Type() string    { return "float64" }
+func (Bool) Type() string       { return "bool" }
+func (Struct) Type() string     { return "struct" }
+func (Array) Type() string      { return "array" }
+func (Map) Type() string        { return "map" }
+func (Star) Type() string       { return "star" }
+func (Named) Type() string      { return "named" }
+func (Union) Type() string      { return "union" }
+func (Interface) Type() string  { return "interface" }
+func (RawMessage) Type() string { return "raw" }
+func (Fallback) Type() string   { return "fallback" }
+func (Enum) Type() string       { return "enum" }
+func (Time) Type() string       { return "time" }
+func (Duration) Type() string   { return "duration" }
+func (Null) Type() string       { return "null" }
+func (Integer) Type() string    { return "integer" }
+func (Text) Type() string       { return "text" }
+func (Bytes) Type() string      { return "bytes" }
 
 type String struct {
 	Depth  int
@@ -206,43 +1160,101 @@ func (Bool) String() string    { return "bool" }
 
 type Struct struct {
 	Fields []StructField
-	Depth  int
-	Target string
+	// Remaining receives the keys that don't match any field
+	Remaining *StructField
+	// Present records the names of the fields that were present
+	Present *StructField
+	// Discriminator is an extra key to accept and ignore when this struct is a
+	// union variant
+	Discriminator string
+	Depth         int
+	Target        string
+	decl          string
 }
 
-func (s *Struct) String() string {
-	out := new(strings.Builder)
-	out.WriteString("struct {\n")
+// HasKey returns true if the struct has a field for key
+func (s *Struct) HasKey(key string) bool {
+	for _, f := range s.Fields {
+		if f.Key == key {
+			return true
+		}
+		for _, alias := range f.Tag.Aliases {
+			if alias == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Field returns the field for key
+func (s *Struct) Field(key string) (StructField, bool) {
 	for _, f := range s.Fields {
-		out.WriteString(fmt.Sprintf("  %s %s\n", f.Key, f.Type.String()))
+		if f.Key == key {
+			return f, true
+		}
 	}
-	out.WriteString("}")
-	return out.String()
+	return StructField{}, false
+}
+
+func (s *Struct) String() string {
+	return s.decl
 }
 
 type StructField struct {
+	Name string
 	Key  string
+	Tag  Tag
 	Type Type
+	// Target is the assignable field (e.g. in.A)
+	Target string
+	// Deprecated is the func called when an alias is used, if any
+	Deprecated string
 }
 
 type Map struct {
-	Key    Type
-	Value  Type
-	Depth  int
-	Target string
+	Key Type
+	// KeyKind is how keys are parsed: string, int, uint or text
+	KeyKind string
+	Value   Type
+	Depth   int
+	Target  string
 }
 
 func (m Map) String() string {
 	return fmt.Sprintf("map[%s]%s", m.Key.String(), m.Value.String())
 }
 
+// KeyBits returns the bit size of integer keys, or 0 for int and uint
+func (m Map) KeyBits() int {
+	key := m.Key
+	if named, ok := key.(*Named); ok {
+		key = named.Underlying
+	}
+	if enum, ok := key.(*Enum); ok {
+		key = enum.Underlying
+	}
+	if i, ok := key.(Integer); ok {
+		return i.Bits()
+	}
+	return 0
+}
+
 type Array struct {
-	Elt    Type
+	Elt Type
+	// Fixed is true for fixed-size arrays of length Len
+	Fixed bool
+	Len   int
+	// Strict rejects extra elements in fixed-size arrays
+	Strict bool
 	Depth  int
 	Target string
 }
 
 func (s Array) String() string {
+	if s.Fixed {
+		return fmt.Sprintf("[%d]%s", s.Len, s.Elt.String())
+	}
 	return fmt.Sprintf("[]%s", s.Elt.String())
 }
 
@@ -255,3 +1267,168 @@ type Star struct {
 func (s Star) String() string {
 	return fmt.Sprintf("*%s", s.X.String())
 }
+
+// Named is a type declared with a name (e.g. `type A struct{}`)
+type Named struct {
+	Name       string
+	Underlying Type
+}
+
+func (n Named) String() string {
+	return n.Name
+}
+
+// Union is an interface decoded by dispatching on a discriminator key
+type Union struct {
+	Key      string
+	Variants []UnionVariant
+	Depth    int
+	Target   string
+}
+
+func (u Union) String() string {
+	return "interface{}"
+}
+
+type UnionVariant struct {
+	Value string
+	Type  Type
+}
+
+// Interface is an empty interface that holds any JSON value
+type Interface struct {
+	Depth  int
+	Target string
+}
+
+func (Interface) String() string { return "interface{}" }
+
+// Enum is a named string or int limited to its constants
+type Enum struct {
+	Name       string
+	Underlying Type
+	Constants  []EnumConstant
+	// Names decodes integer enums from the constant names
+	Names  bool
+	Depth  int
+	Target string
+}
+
+func (e Enum) String() string { return e.Underlying.String() }
+
+// Time is a time.Time decoded inline with its format
+type Time struct {
+	// Pkg is the name of the imported time package
+	Pkg    string
+	Format string
+	// Layout is the expression passed to time.Parse, if any
+	Layout string
+	// Field is reported in errors (e.g. Input.CreatedAt)
+	Field  string
+	Depth  int
+	Target string
+}
+
+func (t Time) String() string { return t.Pkg + ".Time" }
+
+// Duration is a time.Duration decoded from a duration string (e.g. "1m30s") or
+// a number of nanoseconds
+type Duration struct {
+	Pkg    string
+	Field  string
+	Depth  int
+	Target string
+}
+
+func (d Duration) String() string { return d.Pkg + ".Duration" }
+
+// Null is a database/sql Null type (e.g. sql.NullString)
+type Null struct {
+	Name  string
+	Value Type
+	Depth int
+	// Target is the assignable Null type (e.g. in.A)
+	Target string
+	// Base selects the Valid field (e.g. in.A.Valid)
+	Base string
+}
+
+func (n Null) String() string { return n.Name }
+
+// Integer is a sized integer (e.g. int32) that's read as an int64
+type Integer struct {
+	Kind   string
+	Depth  int
+	Target string
+}
+
+func (i Integer) String() string { return i.Kind }
+
+// Base returns the integer type without aliases (e.g. int32 for rune)
+func (i Integer) Base() string {
+	switch i.Kind {
+	case "rune":
+		return "int32"
+	case "byte":
+		return "uint8"
+	default:
+		return i.Kind
+	}
+}
+
+// Bits returns the size of the integer, or 0 for int, uint and uintptr
+func (i Integer) Bits() int {
+	bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(i.Base(), "u"), "int"))
+	if err != nil {
+		return 0
+	}
+	return bits
+}
+
+// Reader returns the scanner method that reads the integer (e.g. ReadInt32)
+func (i Integer) Reader() string {
+	base := i.Base()
+	return "Read" + strings.ToUpper(base[:1]) + base[1:]
+}
+
+// Bytes is a byte slice decoded from a base64 string
+type Bytes struct {
+	// Encoding is the base64 encoding (e.g. base64.StdEncoding)
+	Encoding string
+	Depth    int
+	Target   string
+}
+
+func (Bytes) String() string { return "[]byte" }
+
+// Text is a type decoded with its UnmarshalText method
+type Text struct {
+	Name string
+}
+
+func (t Text) String() string { return t.Name }
+
+type EnumConstant struct {
+	// Name of the constant (e.g. Active)
+	Name string
+	// Value is the qualified constant (e.g. pkg.Active)
+	Value string
+}
+
+// RawMessage holds the raw JSON value
+type RawMessage struct {
+	Name   string
+	Depth  int
+	Target string
+}
+
+func (r RawMessage) String() string { return r.Name }
+
+// Fallback is decoded with encoding/json
+type Fallback struct {
+	Name   string
+	Depth  int
+	Target string
+}
+
+func (f Fallback) String() string { return f.Name }
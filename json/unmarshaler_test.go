@@ -58,10 +58,39 @@ func goRun(ctx context.Context, cacheDir, appDir string) (string, error) {
 }
 
 type Test struct {
-	Dir    string
-	Files  map[string]string
-	Input  string
-	Expect string
+	Dir   string
+	Files map[string]string
+	Input string
+	// MergePatch is applied to the input with MergePatchJSON when set
+	MergePatch string
+	// JSONPatch is applied to the input with PatchJSON when set
+	JSONPatch string
+	// Paths projects the input with ProjectJSON when set
+	Paths []string
+	// Fallbacks are the fields expected to fall back to encoding/json. Falling
+	// back is enabled when set.
+	Fallbacks []string
+	// Deprecated is the func called when an alias key is used
+	Deprecated string
+	// StrictArrays rejects extra elements in fixed-size arrays
+	StrictArrays bool
+	// Lenient coerces values of the wrong type
+	Lenient bool
+	// ExactIntegers accepts integers like 1e3
+	ExactIntegers bool
+	// Strict rejects input that isn't valid JSON according to RFC 8259
+	Strict bool
+	// Relaxed accepts comments and trailing commas
+	Relaxed bool
+	// JSON5 accepts unquoted keys and single-quoted strings
+	JSON5 bool
+	// Stream decodes the input with DecodeStream, printing a line per record
+	Stream bool
+	// Each decodes the array at EachPath with EachJSON, printing a line per
+	// element
+	Each     bool
+	EachPath string
+	Expect   string
 }
 
 const goMod = `
@@ -73,9 +102,14 @@ require (
 `
 
 type State struct {
-	Imports   []*imports.Import
-	Input     string
-	Unmarshal string
+	Imports    []*imports.Import
+	Input      string
+	MergePatch string
+	JSONPatch  string
+	Paths      []string
+	Stream     bool
+	Each       bool
+	Unmarshal  string
 }
 
 var mainGen = template.Must(template.New("main.go").Parse(`
@@ -91,11 +125,60 @@ import (
 {{- end }}
 
 func main() {
+	{{- if $.Each }}
+	if err := printEach(EachJSON, ` + "`" + `{{ .Input }}` + "`" + `); err != nil {
+		fmt.Fprintf(os.Stdout, "%s\n", err)
+	}
+}
+
+func printEach[T any](each func(io.Reader, func(*T) error) error, input string) error {
+	return each(strings.NewReader(input), func(v *T) error {
+		actual, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", string(actual))
+		return nil
+	})
+}
+{{- else if $.Stream }}
+	err := DecodeStream(strings.NewReader(` + "`" + `{{ .Input }}` + "`" + `), func(in *Input) error {
+		actual, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", string(actual))
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "%s\n", err)
+	}
+}
+{{- else }}
 	var in Input
+	{{- if $.Paths }}
+	if err := ProjectJSON([]byte(` + "`" + `{{ .Input }}` + "`" + `), &in{{ range $.Paths }}, ` + "`" + `{{ . }}` + "`" + `{{ end }}); err != nil {
+		fmt.Fprintf(os.Stdout, "%s\n", err)
+		return
+	};
+	{{- else }}
 	if err := UnmarshalJSON([]byte(` + "`" + `{{ .Input }}` + "`" + `), &in); err != nil {
 		fmt.Fprintf(os.Stdout, "%s\n", err)
 		return
 	};
+	{{- end }}
+	{{- if $.MergePatch }}
+	if err := MergePatchJSON([]byte(` + "`" + `{{ .MergePatch }}` + "`" + `), &in); err != nil {
+		fmt.Fprintf(os.Stdout, "%s\n", err)
+		return
+	};
+	{{- end }}
+	{{- if $.JSONPatch }}
+	if err := PatchJSON([]byte(` + "`" + `{{ .JSONPatch }}` + "`" + `), &in); err != nil {
+		fmt.Fprintf(os.Stdout, "%s\n", err)
+		return
+	};
+	{{- end }}
 	actual, err := json.Marshal(in)
 	if err != nil {
 		fmt.Fprintf(os.Stdout, "%s\n", err)
@@ -103,6 +186,7 @@ func main() {
 	}
 	fmt.Fprintf(os.Stdout, "%s", string(actual))
 }
+{{- end }}
 
 {{ $.Unmarshal }}
 `))
@@ -141,9 +225,32 @@ func runTest(t testing.TB, test Test) {
 	imports := imports.Imports{}
 	// Setup the unmarshaler
 	unmarshaler := &json.Unmarshaler{
-		TargetPath: modFile.Module.Mod.Path,
-		Find:       finder.Find,
-		Import:     imports.Import,
+		TargetPath:    modFile.Module.Mod.Path,
+		Find:          finder.Find,
+		Resolve:       finder.Resolve,
+		Constants:     finder.Constants,
+		Constant:      finder.Constant,
+		Import:        imports.Import,
+		MergePatch:    test.MergePatch != "",
+		Patch:         test.JSONPatch != "",
+		Project:       len(test.Paths) > 0,
+		StrictArrays:  test.StrictArrays,
+		Lenient:       test.Lenient,
+		ExactIntegers: test.ExactIntegers,
+		Strict:        test.Strict,
+		Relaxed:       test.Relaxed,
+		UnquotedKeys:  test.JSON5,
+		SingleQuotes:  test.JSON5,
+		Stream:        test.Stream,
+		Each:          test.Each,
+		EachPath:      test.EachPath,
+		Deprecated:    test.Deprecated,
+	}
+	var fallbacks []string
+	if test.Fallbacks != nil {
+		unmarshaler.Fallback = func(field string, err error) {
+			fallbacks = append(fallbacks, field)
+		}
 	}
 	// Generate the unmarshaler
 	unmarshal, err := unmarshaler.Generate("app.com", "Input")
@@ -152,6 +259,9 @@ func runTest(t testing.TB, test Test) {
 		return
 	}
 	// fmt.Println(string(unmarshal))
+	if test.Fallbacks != nil {
+		is.Equal(fallbacks, test.Fallbacks)
+	}
 	// Add main.go's imports
 	_, err = imports.Import("fmt")
 	is.NoErr(err)
@@ -159,12 +269,21 @@ func runTest(t testing.TB, test Test) {
 	is.NoErr(err)
 	_, err = imports.Import("encoding/json")
 	is.NoErr(err)
+	if test.Stream || test.Each {
+		_, err = imports.Import("strings")
+		is.NoErr(err)
+	}
 	// Generate the main.go file
 	mainGo := new(bytes.Buffer)
 	is.NoErr(mainGen.Execute(mainGo, &State{
-		Imports:   imports,
-		Unmarshal: string(unmarshal),
-		Input:     test.Input,
+		Imports:    imports,
+		Unmarshal:  string(unmarshal),
+		Input:      test.Input,
+		MergePatch: test.MergePatch,
+		JSONPatch:  test.JSONPatch,
+		Paths:      test.Paths,
+		Stream:     test.Stream,
+		Each:       test.Each,
 	}))
 	// Write the main.go file out
 	mainPath := filepath.Join(test.Dir, "main.go")
@@ -252,3 +371,1340 @@ func TestNestedStruct(t *testing.T) {
 		Expect: `{"B":"foo","C":1,"D":1.1,"E":true,"F":{"foo":"bar"},"G":[1,2,3],"H":"hello","I":{"B":"foo","C":1,"D":1.1,"E":true,"F":{"foo":"bar"},"G":[1,2,3],"H":"hello"}}`,
 	})
 }
+
+func TestUnion(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				//json:union kind circle=Circle square=*Square
+				type Shape interface {
+					Area() float64
+				}
+				type Circle struct {
+					Radius float64
+				}
+				func (c Circle) Area() float64 { return 3 * c.Radius * c.Radius }
+				type Square struct {
+					Side float64
+				}
+				func (s *Square) Area() float64 { return s.Side * s.Side }
+				type Input struct {
+					A Shape
+					B Shape
+					C Shape
+					D []Shape
+				}
+			`,
+		},
+		Input:  `{"A":{"Radius":2,"kind":"circle"},"B":{"kind":"square","Side":3},"C":null,"D":[{"kind":"circle","Radius":1}]}`,
+		Expect: `{"A":{"Radius":2},"B":{"Side":3},"C":null,"D":[{"Radius":1}]}`,
+	})
+}
+
+func TestUnionJSON5(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				//json:union kind circle=Circle
+				type Shape interface{}
+				type Circle struct {
+					Radius float64
+				}
+				type Input struct {
+					S Shape
+				}
+			`,
+		},
+		JSON5:  true,
+		Input:  `{S: {kind: "circle", Radius: 2}}`,
+		Expect: `{"S":{"Radius":2}}`,
+	})
+}
+
+func TestUnionUnknownKind(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				//json:union kind circle=Circle
+				type Shape interface{}
+				type Circle struct {
+					Radius float64
+				}
+				type Input struct {
+					A Shape
+				}
+			`,
+		},
+		Input:  `{"A":{"kind":"triangle"}}`,
+		Expect: "unknown kind \"triangle\"\n",
+	})
+}
+
+func TestTag(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string ` + "`json:\"a\"`" + `
+					B, C int
+					D chan int ` + "`json:\"-\"`" + `
+					E string ` + "`json:\"-,\"`" + `
+				}
+			`,
+		},
+		Input:  `{"a":"foo","B":1,"C":2,"-":"bar"}`,
+		Expect: `{"a":"foo","B":1,"C":2,"-":"bar"}`,
+	})
+}
+
+func TestNaming(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				//json:naming snake
+				type Input struct {
+					UserID string
+					URLPath string ` + "`json:\"path\"`" + `
+					Nested struct {
+						FirstName string
+					}
+					Camel Camel
+				}
+				//json:naming camel
+				type Camel struct {
+					LastName string
+				}
+			`,
+		},
+		Input:  `{"user_id":"a","path":"b","nested":{"first_name":"c"},"camel":{"lastName":"d"}}`,
+		Expect: `{"UserID":"a","path":"b","Nested":{"FirstName":"c"},"Camel":{"LastName":"d"}}`,
+	})
+}
+
+func TestDuplicateKey(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				//json:naming snake
+				type Input struct {
+					UserID string
+					UserId string
+				}
+			`,
+		},
+		Expect: `fromStruct: UserID and UserId both use the key "user_id"`,
+	})
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Name string ` + "`json:\"name\"`" + `
+					FullName string ` + "`alias:\"name\"`" + `
+				}
+			`,
+		},
+		Expect: `fromStruct: Name and FullName both use the key "name"`,
+	})
+}
+
+func TestAlias(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Name string ` + "`json:\"name\" alias:\"fullName,full_name\"`" + `
+					Age int
+				}
+			`,
+		},
+		Input:  `{"full_name":"Alice","Age":3}`,
+		Expect: `{"name":"Alice","Age":3}`,
+	})
+}
+
+func TestAliasDeprecated(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "fmt"
+				type Input struct {
+					Name string ` + "`json:\"name\" alias:\"fullName\"`" + `
+					Age int
+				}
+				func deprecated(key, alias string) {
+					fmt.Printf("%s is deprecated, use %s\n", alias, key)
+				}
+			`,
+		},
+		Deprecated: "deprecated",
+		Input:      `{"fullName":"Alice","Age":3}`,
+		Expect:     "fullName is deprecated, use name\n" + `{"name":"Alice","Age":3}`,
+	})
+}
+
+func TestEnum(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Status Status
+					Level Level
+				}
+				//json:enum
+				type Status string
+				const (
+					Active Status = "active"
+					Inactive Status = "inactive"
+				)
+				//json:enum
+				type Level int
+				const (
+					Low Level = iota
+					_
+					High
+				)
+			`,
+		},
+		Input:  `{"Status":"inactive","Level":2}`,
+		Expect: `{"Status":"inactive","Level":2}`,
+	})
+}
+
+func TestEnumInvalid(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Status Status
+				}
+				//json:enum
+				type Status string
+				const (
+					Active Status = "active"
+					Inactive Status = "inactive"
+				)
+			`,
+		},
+		Input:  `{"Status":"deleted"}`,
+		Expect: "invalid Status \"deleted\"\n",
+	})
+}
+
+func TestEnumUndeclared(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Port Port
+				}
+				type Port int
+				const DefaultPort Port = 8080
+			`,
+		},
+		Input:  `{"Port":9000}`,
+		Expect: `{"Port":9000}`,
+	})
+}
+
+func TestEnumNames(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Levels []Level
+				}
+				//json:enum names
+				type Level int
+				const (
+					Low Level = iota
+					Medium
+					High
+				)
+			`,
+		},
+		Input:  `{"Levels":["High","Low"]}`,
+		Expect: `{"Levels":[2,0]}`,
+	})
+}
+
+func TestEnumNamesInvalid(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Level Level
+				}
+				//json:enum names
+				type Level int
+				const (
+					Low Level = iota
+					High
+				)
+			`,
+		},
+		Input:  `{"Level":"Extreme"}`,
+		Expect: "invalid Level \"Extreme\"\n",
+	})
+}
+
+func TestTime(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "time"
+				type Input struct {
+					A time.Time
+					B time.Time ` + "`format:\"rfc3339nano\"`" + `
+					C time.Time ` + "`format:\"unix\"`" + `
+					D time.Time ` + "`format:\"unixmilli\"`" + `
+					E *time.Time ` + "`format:\"2006-01-02\"`" + `
+					F, G time.Duration
+				}
+			`,
+		},
+		Input:  `{"A":"2024-01-02T03:04:05Z","B":"2024-01-02T03:04:05.123456789Z","C":1704164645,"D":1704164645123,"E":"2024-01-02","F":"1m30s","G":1000}`,
+		Expect: `{"A":"2024-01-02T03:04:05Z","B":"2024-01-02T03:04:05.123456789Z","C":"2024-01-02T03:04:05Z","D":"2024-01-02T03:04:05.123Z","E":"2024-01-02T00:00:00Z","F":90000000000,"G":1000}`,
+	})
+}
+
+func TestTimeNull(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "time"
+				type Input struct {
+					A time.Time
+					B time.Time ` + "`format:\"unix\"`" + `
+					C time.Time ` + "`format:\"unixmilli\"`" + `
+				}
+			`,
+		},
+		Input:  `{"A":null,"B":null,"C":null}`,
+		Expect: `{"A":"0001-01-01T00:00:00Z","B":"0001-01-01T00:00:00Z","C":"0001-01-01T00:00:00Z"}`,
+	})
+}
+
+func TestTimeError(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "time"
+				type Input struct {
+					Nested struct {
+						CreatedAt time.Time
+					}
+				}
+			`,
+		},
+		Input:  `{"Nested":{"CreatedAt":"yesterday"}}`,
+		Expect: "Input.Nested.CreatedAt: parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\"\n",
+	})
+}
+
+func TestSQLNull(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "database/sql"
+				type Input struct {
+					A, B sql.NullString
+					C sql.NullInt64
+					D sql.NullInt32
+					E sql.NullFloat64
+					F sql.NullBool
+					G *sql.NullTime
+					H []sql.NullByte
+				}
+			`,
+		},
+		Input:  `{"A":"a","B":null,"C":1,"D":null,"E":1.5,"F":false,"G":"2024-01-02T03:04:05Z","H":[7,null]}`,
+		Expect: `{"A":{"String":"a","Valid":true},"B":{"String":"","Valid":false},"C":{"Int64":1,"Valid":true},"D":{"Int32":0,"Valid":false},"E":{"Float64":1.5,"Valid":true},"F":{"Bool":false,"Valid":true},"G":{"Time":"2024-01-02T03:04:05Z","Valid":true},"H":[{"Byte":7,"Valid":true},{"Byte":0,"Valid":false}]}`,
+	})
+}
+
+func TestMapKeys(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import (
+					"net/netip"
+					"strings"
+				)
+				type Input struct {
+					A map[int]string
+					B map[UserID]int
+					C map[Level]bool
+					D map[Upper]int
+					E map[Point]string
+					F map[netip.Addr]string
+				}
+				type UserID string
+				type Level int
+				type Upper string
+				func (u *Upper) UnmarshalText(text []byte) error {
+					*u = Upper(strings.ToUpper(string(text)))
+					return nil
+				}
+				type Point struct {
+					X, Y string
+				}
+				func (p *Point) UnmarshalText(text []byte) error {
+					p.X, p.Y, _ = strings.Cut(string(text), ",")
+					return nil
+				}
+				func (p Point) MarshalText() ([]byte, error) {
+					return []byte(p.X + "," + p.Y), nil
+				}
+			`,
+		},
+		Input:  `{"A":{"2":"b","-1":"a"},"B":{"u1":1},"C":{"3":true},"D":{"abc":1},"E":{"1,2":"p"},"F":{"127.0.0.1":"localhost"}}`,
+		Expect: `{"A":{"-1":"a","2":"b"},"B":{"u1":1},"C":{"3":true},"D":{"ABC":1},"E":{"1,2":"p"},"F":{"127.0.0.1":"localhost"}}`,
+	})
+}
+
+func TestMapKeyInvalid(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A map[int]string
+				}
+			`,
+		},
+		Input:  `{"A":{"a":"b"}}`,
+		Expect: "invalid map key \"a\": strconv.ParseInt: parsing \"a\": invalid syntax\n",
+	})
+}
+
+func TestMapKeyIntegers(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A map[int8]string
+					B map[uint]string
+					C map[uint64]string
+				}
+			`,
+		},
+		Input:  `{"A":{"-128":"a"},"B":{"1":"b"},"C":{"18446744073709551615":"c"}}`,
+		Expect: `{"A":{"-128":"a"},"B":{"1":"b"},"C":{"18446744073709551615":"c"}}`,
+	})
+}
+
+func TestMapKeyOverflow(t *testing.T) {
+	files := map[string]string{
+		"input.go": `
+			package main
+			type Input struct {
+				A map[int8]string
+				B map[uint]string
+				C map[uint64]string
+			}
+		`,
+	}
+	runTest(t, Test{
+		Files:  files,
+		Input:  `{"A":{"300":"a"}}`,
+		Expect: "invalid map key \"300\": strconv.ParseInt: parsing \"300\": value out of range\n",
+	})
+	runTest(t, Test{
+		Files:  files,
+		Input:  `{"B":{"-1":"b"}}`,
+		Expect: "invalid map key \"-1\": strconv.ParseUint: parsing \"-1\": invalid syntax\n",
+	})
+	runTest(t, Test{
+		Files:  files,
+		Input:  `{"C":{"18446744073709551616":"c"}}`,
+		Expect: "invalid map key \"18446744073709551616\": strconv.ParseUint: parsing \"18446744073709551616\": value out of range\n",
+	})
+}
+
+func TestFixedArray(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "app.com/size"
+				type Input struct {
+					A [4]byte
+					B [Size]float64
+					C [size.Small][2]int8
+					D [2]string
+				}
+				const Size = size.Small * 2
+			`,
+			"size/size.go": `
+				package size
+				const (
+					_ = iota
+					Small
+				)
+			`,
+		},
+		Input:  `{"A":[1,2,3,4,5],"B":[1.5],"C":[[1,-2]],"D":null}`,
+		Expect: `{"A":[1,2,3,4],"B":[1.5,0],"C":[[1,-2]],"D":["",""]}`,
+	})
+}
+
+func TestFixedArrayTopLevel(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input [3]int
+			`,
+		},
+		Input:  `[1,2]`,
+		Expect: `[1,2,0]`,
+	})
+}
+
+func TestFixedArrayPointer(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A *[2]int
+					B *[2]int
+				}
+			`,
+		},
+		Input:  `{"A":[1,2,3],"B":null}`,
+		Expect: `{"A":[1,2],"B":null}`,
+	})
+}
+
+func TestFixedArrayStrict(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A [2]int
+				}
+			`,
+		},
+		StrictArrays: true,
+		Input:        `{"A":[1,2,3]}`,
+		Expect:       "line 1, column 11: too many elements for [2]int\n",
+	})
+}
+
+func TestFixedArrayLength(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A [Size]int
+				}
+			`,
+		},
+		Expect: `finder:could not find constant "app.com".Size`,
+	})
+}
+
+func TestBytes(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A []byte
+					B []byte ` + "`format:\"base64url\"`" + `
+					C Blob
+					D [][]uint8
+					E []byte
+				}
+				type Blob []byte
+			`,
+		},
+		Input:  `{"A":"aGk/Pz4+","B":"aGk_Pz4-","C":"YmxvYg==","D":["YQ==",null],"E":null}`,
+		Expect: `{"A":"aGk/Pz4+","B":"aGk/Pz4+","C":"YmxvYg==","D":["YQ==",null],"E":null}`,
+	})
+}
+
+func TestTypeMismatch(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+				}
+			`,
+		},
+		Input:  `{"A":"abc"}`,
+		Expect: "unexpected string at line 1, column 6: abc; expected number\n",
+	})
+}
+
+func TestLenient(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+					B bool
+					C string
+					D []float64
+				}
+			`,
+		},
+		Lenient: true,
+		Input:   `{"A":"42","B":"true","C":12,"D":["1.5",2]}`,
+		Expect:  `{"A":42,"B":true,"C":"12","D":[1.5,2]}`,
+	})
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int8
+				}
+			`,
+		},
+		Input:  `{"A":300}`,
+		Expect: "invalid int8 at line 1, column 6: 300 overflows\n",
+	})
+}
+
+func TestIntegerFraction(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+				}
+			`,
+		},
+		Input:  `{"A":1.5}`,
+		Expect: "invalid int at line 1, column 6: 1.5 has a fraction\n",
+	})
+}
+
+func TestExactIntegers(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+					B []uint16
+				}
+			`,
+		},
+		ExactIntegers: true,
+		Input:         `{"A":1e3,"B":[2.0,6.5e1]}`,
+		Expect:        `{"A":1000,"B":[2,65]}`,
+	})
+}
+
+func TestErrorPosition(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+					B int
+				}
+			`,
+		},
+		Input:  "{\n  \"A\": 1,\n  \"B\": \"x\"\n}",
+		Expect: "unexpected string at line 3, column 8: x; expected number\n",
+	})
+}
+
+func TestUnionErrorPosition(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				//json:union kind circle=Circle
+				type Shape interface{}
+				type Circle struct {
+					Radius float64
+				}
+				type Input struct {
+					A int
+					B Shape
+				}
+			`,
+		},
+		Input:  "{\n  \"A\": 1,\n  \"B\": {\"kind\": \"circle\",\n    \"Radius\": \"x\"}\n}",
+		Expect: "unexpected string at line 4, column 15: x; expected number\n",
+	})
+}
+
+func TestStructErrorPosition(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A struct {
+						B int
+					}
+				}
+			`,
+		},
+		Input:  `{"A": 1}`,
+		Expect: "unexpected number at line 1, column 7: 1; expected left brace\n",
+	})
+}
+
+func TestSurrogatePairs(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string
+					B string
+				}
+			`,
+		},
+		Input:  `{"A":"hi \ud83d\ude00","B":"\ud83d!"}`,
+		Expect: `{"A":"hi 😀","B":"�!"}`,
+	})
+}
+
+func TestStrict(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A []int
+					B string
+				}
+			`,
+		},
+		Strict: true,
+		Input:  `{"A":[1,2],"B":"\ud83d\ude00"}`,
+		Expect: `{"A":[1,2],"B":"😀"}`,
+	})
+}
+
+func TestStrictError(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A []int
+				}
+			`,
+		},
+		Strict: true,
+		Input:  `{"A":[1,02]}`,
+		Expect: "invalid number at line 1, column 9: 02\n",
+	})
+}
+
+func TestStrictTrailing(t *testing.T) {
+	files := map[string]string{
+		"input.go": `
+			package main
+			type Input struct {
+				A struct{}
+			}
+		`,
+	}
+	runTest(t, Test{
+		Files:  files,
+		Strict: true,
+		Input:  `{"A":{}} garbage`,
+		Expect: "unexpected char at line 1, column 10: 'g'\n",
+	})
+	runTest(t, Test{
+		Files:  files,
+		Strict: true,
+		Input:  `{"A":{}}}`,
+		Expect: "line 1, column 9: unexpected right brace after the top-level value\n",
+	})
+	runTest(t, Test{
+		Files:      files,
+		Strict:     true,
+		Input:      `{"A":{}} `,
+		MergePatch: `null []`,
+		Expect:     "line 1, column 6: unexpected left bracket after the top-level value\n",
+	})
+}
+
+func TestStream(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+					B []string
+					C map[string]int
+				}
+			`,
+		},
+		Stream: true,
+		Input:  "{\"A\":1,\"B\":[\"x\"],\"C\":{\"a\":1}}\n{\"A\":2}\n\n{\"C\":{\"b\":2}}\n",
+		Expect: "{\"A\":1,\"B\":[\"x\"],\"C\":{\"a\":1}}\n{\"A\":2,\"B\":null,\"C\":null}\n{\"A\":0,\"B\":null,\"C\":{\"b\":2}}\n",
+	})
+}
+
+func TestStreamError(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A int
+				}
+			`,
+		},
+		Stream: true,
+		Input:  "{\"A\":1}\n{\"A\":2}\n{\"A\":\"3\"}\n{\"A\":4}\n",
+		Expect: "{\"A\":1}\n{\"A\":2}\nrecord 3: unexpected string at line 3, column 6: 3; expected number\n",
+	})
+}
+
+func TestTopLevelArray(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input []map[string]int
+			`,
+		},
+		Input:  `[{"a":1},{"b":2}]`,
+		Expect: `[{"a":1},{"b":2}]`,
+	})
+}
+
+func TestEach(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input []Item
+				type Item struct {
+					A int
+					B []string
+				}
+			`,
+		},
+		Each:   true,
+		Input:  `[{"A":1,"B":["x"]},{"A":2},{"B":["y","z"]}]`,
+		Expect: "{\"A\":1,\"B\":[\"x\"]}\n{\"A\":2,\"B\":null}\n{\"A\":0,\"B\":[\"y\",\"z\"]}\n",
+	})
+}
+
+func TestEachNested(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Meta map[string]string
+					Data *Data ` + "`json:\"data\"`" + `
+				}
+				type Data struct {
+					Items []int ` + "`json:\"items\"`" + `
+				}
+			`,
+		},
+		Each:     true,
+		EachPath: "/data/items",
+		Input:    `{"Meta":{"a":"b"},"data":{"count":3,"items":[1,2,3],"next":null},"more":[4]}`,
+		Expect:   "1\n2\n3\n",
+	})
+}
+
+func TestEachError(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Items []int
+				}
+			`,
+		},
+		Each:     true,
+		EachPath: "/Items",
+		Input:    `{"Items":[1,"2",3]}`,
+		Expect:   "1\nunexpected string at line 1, column 13: 2; expected number\n",
+	})
+}
+
+func TestEachInvalidPath(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Items []int
+				}
+			`,
+		},
+		Each:     true,
+		EachPath: "/Items/0",
+		Expect:   `findEach: "/Items/0" is an array, not an object`,
+	})
+}
+
+func TestRelaxed(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Name  string
+					Ports []int
+					Env   map[string]string
+				}
+			`,
+		},
+		Relaxed: true,
+		Input: `{
+			// The name of the service
+			"Name": "api", /* inline */
+			"Ports": [80, 443,],
+			"Env": {"A": "1",},
+		}`,
+		Expect: `{"Name":"api","Ports":[80,443],"Env":{"A":"1"}}`,
+	})
+}
+
+func TestJSON5(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Name  string ` + "`json:\"name\"`" + `
+					Debug bool   ` + "`json:\"debug\"`" + `
+					Tags  []string
+				}
+			`,
+		},
+		Relaxed: true,
+		JSON5:   true,
+		Input:   `{name: 'api', debug: true, Tags: ['a', "b",],}`,
+		Expect:  `{"name":"api","debug":true,"Tags":["a","b"]}`,
+	})
+}
+
+func TestRelaxedOff(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Ports []int
+				}
+			`,
+		},
+		Input:  `{"Ports": [80,]}`,
+		Expect: "line 1, column 15: unexpected trailing comma\n",
+	})
+}
+
+func TestRemaining(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string
+					Extra map[string]any ` + "`json:\",remaining\"`" + `
+				}
+			`,
+		},
+		Input:  `{"A":"foo","B":1,"C":{"D":[true,null,"E"]}}`,
+		Expect: `{"A":"foo","Extra":{"B":1,"C":{"D":[true,null,"E"]}}}`,
+	})
+}
+
+func TestRemainingRawMessage(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "encoding/json"
+				type Input struct {
+					A string
+					Extra map[string]json.RawMessage ` + "`json:\",remaining\"`" + `
+				}
+			`,
+		},
+		Input:  `{"B": 1.50, "A": "foo", "C": {"D": [true, null, "E"]}}`,
+		Expect: `{"A":"foo","Extra":{"B":1.50,"C":{"D":[true,null,"E"]}}}`,
+	})
+}
+
+func TestRemainingInvalidType(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					Extra map[string]string ` + "`json:\",remaining\"`" + `
+				}
+			`,
+		},
+		Expect: `fromStruct: remaining field Extra must be a map[string]any or map[string]json.RawMessage`,
+	})
+}
+
+func TestPresent(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string ` + "`json:\"a\"`" + `
+					B int
+					C *string
+					D bool
+					Has map[string]bool ` + "`json:\",present\"`" + `
+				}
+			`,
+		},
+		Input:  `{"a":"","C":null,"B":0}`,
+		Expect: `{"a":"","B":0,"C":null,"D":false,"Has":{"A":true,"B":true,"C":true}}`,
+	})
+}
+
+func TestPresentProject(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string
+					B int
+					Has map[string]bool ` + "`json:\",present\"`" + `
+				}
+			`,
+		},
+		Input:  `{"A":"a","B":1}`,
+		Paths:  []string{"/B"},
+		Expect: `{"A":"","B":1,"Has":{"B":true}}`,
+	})
+}
+
+func TestMergePatch(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Inner struct {
+					C int
+					D int
+				}
+				type Input struct {
+					A string
+					B Inner
+					E map[string]Inner
+					F []int
+					G *string
+					H *Inner
+					I *Inner
+				}
+			`,
+		},
+		Input:      `{"A":"a","B":{"C":1,"D":2},"E":{"x":{"C":1,"D":2},"y":{"C":3}},"F":[1,2],"G":"g","I":{"C":1,"D":2}}`,
+		MergePatch: `{"A":null,"B":{"D":3},"E":{"x":{"D":5},"y":null,"z":{"C":9}},"F":[3],"H":{"C":4},"I":{"C":5}}`,
+		Expect:     `{"A":"","B":{"C":1,"D":3},"E":{"x":{"C":1,"D":5},"z":{"C":9,"D":0}},"F":[3],"G":"g","H":{"C":4,"D":0},"I":{"C":5,"D":2}}`,
+	})
+}
+
+func TestMergePatchNull(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string
+					B []int
+				}
+			`,
+		},
+		Input:      `{"A":"a","B":[1]}`,
+		MergePatch: `null`,
+		Expect:     `{"A":"","B":null}`,
+	})
+}
+
+func TestJSONPatch(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Inner struct {
+					C int
+					D []string
+				}
+				type Input struct {
+					A string ` + "`json:\"a\"`" + `
+					B Inner
+					E map[string]*Inner
+					F []int
+				}
+			`,
+		},
+		Input: `{"a":"a","B":{"C":1,"D":["x"]},"E":{"x":{"C":2}},"F":[1,2]}`,
+		JSONPatch: `[
+			{"op":"replace","path":"/a","value":"b"},
+			{"op":"add","path":"/B/D/0","value":"w"},
+			{"op":"add","path":"/B/D/-","value":"y"},
+			{"op":"test","path":"/B/D","value":["w","x","y"]},
+			{"op":"add","path":"/E/y","value":{"C":3}},
+			{"op":"replace","path":"/E/x/C","value":4},
+			{"op":"copy","from":"/E/x","path":"/E/z"},
+			{"op":"move","from":"/F/0","path":"/F/1"},
+			{"op":"remove","path":"/E/y"},
+			{"op":"add","path":"/E/a~1b","value":null}
+		]`,
+		Expect: `{"a":"b","B":{"C":1,"D":["w","x","y"]},"E":{"a/b":null,"x":{"C":4,"D":null},"z":{"C":4,"D":null}},"F":[2,1]}`,
+	})
+}
+
+func TestJSONPatchMapKeys(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A map[int]string
+				}
+			`,
+		},
+		Input: `{"A":{"1":"a"}}`,
+		JSONPatch: `[
+			{"op":"add","path":"/A/2","value":"b"},
+			{"op":"remove","path":"/A/1"}
+		]`,
+		Expect: `{"A":{"2":"b"}}`,
+	})
+}
+
+func TestJSONPatchFixedArray(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A [2]int
+				}
+			`,
+		},
+		Input:     `{"A":[1,2]}`,
+		JSONPatch: `[{"op":"replace","path":"/A/1","value":3},{"op":"add","path":"/A/0","value":0}]`,
+		Expect:    "patch: operation 1 (add /A/0): can't add elements of [2]int\n",
+	})
+}
+
+func TestJSONPatchTopLevel(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input []int
+			`,
+		},
+		Input: `[1,2,3]`,
+		JSONPatch: `[
+			{"op":"add","path":"/-","value":4},
+			{"op":"remove","path":"/0"},
+			{"op":"replace","path":"/0","value":5}
+		]`,
+		Expect: `[5,3,4]`,
+	})
+}
+
+func TestJSONPatchTopLevelMap(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input map[string][2]int
+			`,
+		},
+		Input: `{"a":[1,2]}`,
+		JSONPatch: `[
+			{"op":"add","path":"/b","value":[3,4]},
+			{"op":"replace","path":"/a/1","value":5},
+			{"op":"remove","path":"/a"}
+		]`,
+		Expect: `{"b":[3,4]}`,
+	})
+}
+
+func TestJSONPatchCopy(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "time"
+				//json:naming snake
+				type Person struct {
+					FirstName string
+					Tags []string
+				}
+				//json:union kind circle=Circle
+				type Shape interface{ shape() }
+				type Circle struct {
+					Radius int
+				}
+				func (Circle) shape() {}
+				//json:naming snake
+				type Input struct {
+					Owner Person
+					Friends map[string]Person
+					CreatedAt time.Time ` + "`format:\"unix\"`" + `
+					UpdatedAt time.Time ` + "`format:\"unix\"`" + `
+					Shapes []Shape
+					Extra interface{}
+					Other interface{}
+				}
+			`,
+		},
+		Input: `{"owner":{"first_name":"a","tags":["x"]},"created_at":1704164645,"shapes":[{"kind":"circle","Radius":1}],"extra":{"b":[1]}}`,
+		JSONPatch: `[
+			{"op":"copy","from":"/owner","path":"/friends/b"},
+			{"op":"add","path":"/friends/b/tags/-","value":"y"},
+			{"op":"replace","path":"/friends/b/first_name","value":"b"},
+			{"op":"copy","from":"/created_at","path":"/updated_at"},
+			{"op":"test","path":"/updated_at","value":1704164645},
+			{"op":"copy","from":"/shapes/0","path":"/shapes/-"},
+			{"op":"test","path":"/shapes","value":[{"kind":"circle","Radius":1},{"kind":"circle","Radius":1}]},
+			{"op":"move","from":"/extra","path":"/other"},
+			{"op":"test","path":"/other","value":{"b":[1]}}
+		]`,
+		Expect: `{"Owner":{"FirstName":"a","Tags":["x"]},"Friends":{"b":{"FirstName":"b","Tags":["x","y"]}},"CreatedAt":"2024-01-02T03:04:05Z","UpdatedAt":"2024-01-02T03:04:05Z","Shapes":[{"Radius":1},{"Radius":1}],"Extra":null,"Other":{"b":[1]}}`,
+	})
+}
+
+func TestJSONPatchCopyError(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A string
+					B int
+				}
+			`,
+		},
+		Input:     `{"A":"a"}`,
+		JSONPatch: `[{"op":"copy","from":"/A","path":"/B"}]`,
+		Expect:    "patch: operation 0 (copy /B): can't copy string into int\n",
+	})
+}
+
+func TestJSONPatchError(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A []int
+				}
+			`,
+		},
+		Input:     `{"A":[1]}`,
+		JSONPatch: `[{"op":"test","path":"/A/0","value":1},{"op":"replace","path":"/A/1","value":2}]`,
+		Expect:    "patch: operation 1 (replace /A/1): path not found: index 1 out of range\n",
+	})
+}
+
+func TestProject(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Item struct {
+					Name  string ` + "`json:\"name\"`" + `
+					Price float64 ` + "`json:\"price\"`" + `
+				}
+				type Input struct {
+					A string
+					B map[string]Item
+					C []Item
+					D *Item
+					E int
+				}
+			`,
+		},
+		Input:  `{"A":"a","B":{"x":{"name":"x","price":1},"y":{"name":"y"}},"C":[{"name":"c","price":2}],"D":{"name":"d","price":3},"E":1,"F":{"G":[1,{}]}}`,
+		Paths:  []string{"/A", "/B/x/price", "/C/name", "/D"},
+		Expect: `{"A":"a","B":{"x":{"name":"","price":1}},"C":[{"name":"c","price":0}],"D":{"name":"d","price":3},"E":0}`,
+	})
+}
+
+func TestFallback(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				import "math/big"
+				type Node struct {
+					Name     string
+					Children []Node
+				}
+				type Input struct {
+					A uintptr
+					B Node
+					C *big.Int
+					D string
+				}
+			`,
+		},
+		Fallbacks: []string{"Input.A", "Input.B.Children", "Input.C"},
+		Input:     `{"A":9007199254740993,"B":{"Name":"a","Children":[{"Name":"b","Children":null}]},"C":12345678901234567890,"D":"d"}`,
+		Expect:    `{"A":9007199254740993,"B":{"Name":"a","Children":[{"Name":"b","Children":null}]},"C":12345678901234567890,"D":"d"}`,
+	})
+}
+
+func TestNoFallback(t *testing.T) {
+	runTest(t, Test{
+		Files: map[string]string{
+			"input.go": `
+				package main
+				type Input struct {
+					A uintptr
+				}
+			`,
+		},
+		Expect: `fromIdent: "uintptr" not implemented`,
+	})
+}
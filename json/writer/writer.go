@@ -1,6 +1,7 @@
 package writer
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"strconv"
@@ -158,6 +159,43 @@ func (w *Writer) WriteString(v string) error {
 	return nil
 }
 
+// WriteBase64 writes the bytes as a base64 JSON string. Nil bytes are written
+// as null, like encoding/json.
+func (w *Writer) WriteBase64(v []byte, enc *base64.Encoding) error {
+	if v == nil {
+		return w.WriteNull()
+	}
+	if err := w.check(); err != nil {
+		return err
+	}
+	w.writeByte('"')
+	for len(v) > 0 {
+		if err := w.check(); err != nil {
+			return err
+		}
+		// Encode as many 3 byte groups as fit in the buffer, so only the last
+		// chunk is padded
+		n := (len(w.buf) - w.pos - 1) / 4 * 3
+		if n == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		if n >= len(v) {
+			n = len(v)
+		}
+		enc.Encode(w.buf[w.pos:], v[:n])
+		w.pos += enc.EncodedLen(n)
+		v = v[n:]
+	}
+	if err := w.check(); err != nil {
+		return err
+	}
+	w.writeByte('"')
+	return nil
+}
+
 // WriteInt encodes and writes an integer.
 func (w *Writer) WriteInt(v int) error {
 	return w.WriteInt64(int64(v))
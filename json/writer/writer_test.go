@@ -2,6 +2,7 @@ package writer
 
 import (
 	"bytes"
+	"encoding/base64"
 	"strings"
 	"testing"
 
@@ -270,6 +271,29 @@ func BenchmarkWriteFloat64(b *testing.B) {
 	b.SetBytes(int64(len(`2.319123192191827e+06`)))
 }
 
+// Ensures that bytes can be written as base64.
+func TestWriteBase64(t *testing.T) {
+	is := is.New(t)
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	is.NoErr(w.WriteBase64([]byte("hi??>>"), base64.StdEncoding))
+	is.NoErr(w.WriteBase64([]byte("hi??>>"), base64.URLEncoding))
+	is.NoErr(w.WriteBase64(nil, base64.StdEncoding))
+	is.NoErr(w.Flush())
+	is.Equal(b.String(), `"aGk/Pz4+""aGk_Pz4-"null`)
+}
+
+// Ensures that bytes larger than the buffer can be written as base64.
+func TestWriteBase64Large(t *testing.T) {
+	is := is.New(t)
+	input := bytes.Repeat([]byte("abcdefg"), 50000)
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	is.NoErr(w.WriteBase64(input, base64.StdEncoding))
+	is.NoErr(w.Flush())
+	is.Equal(b.String(), `"`+base64.StdEncoding.EncodeToString(input)+`"`)
+}
+
 // Ensures that a single byte can be written to the writer.
 func TestWriteByte(t *testing.T) {
 	is := is.New(t)